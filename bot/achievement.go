@@ -0,0 +1,208 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/etterna"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	longChartNotes = 1500 // Number of notes for a chart to count as long
+)
+
+// achievement is a rule that a play is checked against. Once a user meets the rule
+// the achievement is unlocked and can't be unlocked again
+type achievement struct {
+	id          string // Saved with unlocked achievements, so it shouldn't change
+	name        string
+	description string
+	unlocked    func(p *achievementPlay) bool
+}
+
+// achievementPlay is what achievements are checked against: the new play, the user
+// after their ratings were updated and counts from their score history (including
+// the new play)
+type achievementPlay struct {
+	score *etterna.Score
+	user  *model.EtternaUser
+	plays int // Number of scores in the user's history
+	aaas  int // Number of AAAs (or better) in the user's history
+}
+
+// All of the achievements, in the order they are listed in the badges command
+var achievements = []achievement{
+	{
+		id:          "first_aaa",
+		name:        "Triple A",
+		description: "Get your first AAA",
+		unlocked: func(p *achievementPlay) bool {
+			return p.score.Accuracy >= etterna.GradeAAA.MinAccuracy()
+		},
+	},
+	{
+		id:          "first_aaaa",
+		name:        "Quad",
+		description: "Get your first AAAA",
+		unlocked: func(p *achievementPlay) bool {
+			return p.score.Accuracy >= etterna.GradeAAAA.MinAccuracy()
+		},
+	},
+	{
+		id:          "aaa_100",
+		name:        "AAA Collector",
+		description: "Get 100 AAAs",
+		unlocked: func(p *achievementPlay) bool {
+			return p.aaas >= 100
+		},
+	},
+	{
+		id:          "plays_1000",
+		name:        "Dedicated",
+		description: "Have 1000 plays tracked",
+		unlocked: func(p *achievementPlay) bool {
+			return p.plays >= 1000
+		},
+	},
+	{
+		id:          "ssr_30",
+		name:        "Thirty",
+		description: "Get a score rated 30 or higher",
+		unlocked: func(p *achievementPlay) bool {
+			return p.score.Overall >= 30
+		},
+	},
+	{
+		id:          "long_full_combo",
+		name:        "Marathon",
+		description: fmt.Sprintf("Don't miss on a chart with at least %d notes", longChartNotes),
+		unlocked: func(p *achievementPlay) bool {
+			s := p.score
+			notes := s.Marvelous + s.Perfect + s.Great + s.Good + s.Bad + s.Miss
+
+			return notes >= longChartNotes && s.Miss == 0
+		},
+	},
+	{
+		id:          "mines_aaa",
+		name:        "Minesweeper",
+		description: "Get an AAA while hitting a mine",
+		unlocked: func(p *achievementPlay) bool {
+			return p.score.MinesHit > 0 && p.score.Accuracy >= etterna.GradeAAA.MinAccuracy()
+		},
+	},
+}
+
+// getAchievement returns the achievement with the given ID, or nil if it doesn't exist
+func getAchievement(id string) *achievement {
+	for i := range achievements {
+		if achievements[i].id == id {
+			return &achievements[i]
+		}
+	}
+
+	return nil
+}
+
+// checkAchievements unlocks any achievements the user earned with the play. The play
+// should already be saved to the score history. Returns a summary of the unlocked
+// achievements
+func checkAchievements(bot *eb.Bot, user *model.EtternaUser, score *etterna.Score) string {
+	var err error
+
+	p := &achievementPlay{score: score, user: user}
+
+	if p.plays, err = bot.Scores.CountTracked(user.ID, 0); err != nil {
+		fmt.Println("Failed to count scores for achievements", user.Username, err)
+		return ""
+	}
+
+	if p.aaas, err = bot.Scores.CountTracked(user.ID, etterna.GradeAAA.MinAccuracy()); err != nil {
+		fmt.Println("Failed to count scores for achievements", user.Username, err)
+		return ""
+	}
+
+	unlocked := ""
+
+	for _, a := range achievements {
+		if !a.unlocked(p) {
+			continue
+		}
+
+		ok, err := bot.Achievements.Unlock(user.ID, a.id, score.Key)
+
+		if err != nil {
+			fmt.Println("Failed to unlock achievement", a.id, user.Username, err)
+			continue
+		} else if !ok {
+			continue
+		}
+
+		unlocked += fmt.Sprintf("🏆 **Achievement unlocked:** %s (%s)\n", a.name, a.description)
+	}
+
+	return strings.TrimSuffix(unlocked, "\n")
+}
+
+// CmdBadges lists the achievements that a user has unlocked, and the ones they haven't
+func CmdBadges(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	var err error
+	var user *model.EtternaUser
+
+	if len(args) == 1 {
+		user, err = bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID)
+	} else {
+		user, err = getUserOrCreate(bot, args[1], false)
+	}
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if user == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You are not registered with an Etterna user. "+
+			"Please register using the `setuser` command, or specify a user: badges <username>")
+		return
+	}
+
+	unlocked, err := bot.Achievements.GetUnlocked(user.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	var description string
+	has := make(map[string]bool)
+
+	for _, u := range unlocked {
+		a := getAchievement(u.AchievementID)
+
+		if a == nil {
+			continue
+		}
+
+		has[a.id] = true
+		description += fmt.Sprintf("🏆 **%s** — %s (%s)\n", a.name, a.description, u.CreatedAt.Format("Jan 2, 2006"))
+	}
+
+	for _, a := range achievements {
+		if !has[a.id] {
+			description += fmt.Sprintf("🔒 %s — %s\n", a.name, a.description)
+		}
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Color:       embedColor,
+		Description: description,
+		Author: &discordgo.MessageEmbedAuthor{
+			IconURL: bot.API.BaseURL() + "/avatars/" + user.Avatar,
+			Name:    fmt.Sprintf("%s's badges (%d/%d)", user.Username, len(has), len(achievements)),
+			URL:     bot.API.BaseURL() + "/user/" + user.Username,
+		},
+	}
+
+	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/Kangaroux/etternabot/model/service"
 	"github.com/bwmarrin/discordgo"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 const (
@@ -22,6 +23,8 @@ const (
 )
 
 var (
+	defaultRankMilestones = pq.Int64Array{1000, 500, 100} // Global ranks to call out in recent plays
+
 	reCommand  = regexp.MustCompile(`^[a-z\d\.@]+$`)
 	reScoreURL = regexp.MustCompile(`etternaonline\.com\/score\/view\/(S[a-f0-9]+)`)
 )
@@ -29,22 +32,48 @@ var (
 // New returns a new discord bot instance that is ready to be started
 func New(s *discordgo.Session, db *sqlx.DB, etternaAPIKey string) eb.Bot {
 	bot := eb.Bot{
-		DB:      db,
-		API:     etterna.New(etternaAPIKey),
-		Session: s,
-		Servers: service.NewDiscordServerService(db),
-		Songs:   service.NewSongService(db),
-		Users:   service.NewUserService(db),
+		DB:            db,
+		API:           etterna.New(etternaAPIKey),
+		Session:       s,
+		Achievements:  service.NewAchievementService(db),
+		Challenges:    service.NewChallengeService(db),
+		Channels:      service.NewDiscordChannelService(db),
+		Goals:         service.NewGoalService(db),
+		Matches:       service.NewMatchService(db),
+		Posted:        service.NewPostedScoreService(db),
+		Rivals:        service.NewRivalService(db),
+		Roles:         service.NewRoleRewardService(db),
+		Servers:       service.NewDiscordServerService(db),
+		Scores:        service.NewScoreService(db),
+		Songs:         service.NewSongService(db),
+		Users:         service.NewUserService(db),
+		Verifications: service.NewVerificationService(db),
 	}
 
 	s.AddHandler(func(s *discordgo.Session, g *discordgo.GuildCreate) {
 		guildCreate(&bot, g)
 	})
 
+	s.AddHandler(func(s *discordgo.Session, c *discordgo.GuildMembersChunk) {
+		addGuildMembers(&bot, c.GuildID, c.Members)
+	})
+
+	s.AddHandler(func(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+		guildMemberAdd(&bot, m)
+	})
+
+	s.AddHandler(func(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+		guildMemberRemove(&bot, m)
+	})
+
 	s.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
 		messageCreate(&bot, m)
 	})
 
+	s.AddHandler(func(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+		messageReactionAdd(&bot, r)
+	})
+
 	s.AddHandlerOnce(func(s *discordgo.Session, r *discordgo.Ready) {
 		ready(&bot, r)
 	})
@@ -62,8 +91,11 @@ func guildCreate(bot *eb.Bot, g *discordgo.GuildCreate) {
 
 	if server == nil {
 		server = &model.DiscordServer{
-			CommandPrefix: defaultPrefix,
-			ServerID:      g.ID,
+			CommandPrefix:   defaultPrefix,
+			ServerID:        g.ID,
+			RankMilestones:  defaultRankMilestones,
+			DigestFrequency: model.DigestOff,
+			DigestSections:  defaultDigestSections,
 		}
 
 		if err := bot.Servers.Save(server); err != nil {
@@ -73,6 +105,14 @@ func guildCreate(bot *eb.Bot, g *discordgo.GuildCreate) {
 
 		fmt.Println("Created record for server", g.Name)
 	}
+
+	// Find out which linked users are in the server. Discord only sends the members of
+	// small servers here, the rest come in chunks
+	addGuildMembers(bot, g.ID, g.Members)
+
+	if err := bot.Session.RequestGuildMembers(g.ID, "", 0, "", false); err != nil {
+		fmt.Println("Failed to request guild members", g.ID, err)
+	}
 }
 
 func messageCreate(bot *eb.Bot, m *discordgo.MessageCreate) {
@@ -105,18 +145,60 @@ func messageCreate(bot *eb.Bot, m *discordgo.MessageCreate) {
 	}
 
 	switch cmdParts[0] {
+	case "badges":
+		CmdBadges(bot, m, cmdParts)
+	case "challenge":
+		CmdChallenge(bot, m, cmdParts)
 	case "compare":
 		CmdCompare(bot, server, m, cmdParts)
+	case "digest":
+		CmdDigest(bot, server, m, cmdParts)
+	case "goal":
+		CmdGoal(bot, m, cmdParts)
+	case "graph":
+		CmdGraph(bot, m, cmdParts)
+	case "h2h":
+		CmdHeadToHead(bot, m, cmdParts)
 	case "help":
-		CmdHelp(bot, server, m)
+		CmdHelp(bot, server, m, cmdParts)
+	case "match":
+		CmdMatch(bot, m, cmdParts)
+	case "members":
+		CmdMembers(bot, m, cmdParts)
+	case "milestones":
+		CmdSetRankMilestones(bot, server, m, cmdParts)
+	case "nickname":
+		CmdNickname(bot, server, m, cmdParts)
+	case "pick":
+		CmdPickSong(bot, server, m, cmdParts)
 	case "profile":
 		CmdProfile(bot, m, cmdParts)
+	case "progress":
+		CmdProgress(bot, m, cmdParts)
 	case "recent":
 		CmdRecentPlay(bot, server, m, cmdParts)
+	case "recommend":
+		CmdRecommend(bot, m, cmdParts)
+	case "rival":
+		CmdRival(bot, m, cmdParts)
+	case "roles":
+		CmdRoles(bot, m, cmdParts)
+	case "serverlb":
+		CmdServerLeaderboard(bot, m, cmdParts)
+	case "song":
+		CmdSongSearch(bot, m, cmdParts)
+	case "songlb":
+		CmdSongLeaderboard(bot, server, m, cmdParts)
 	case "setuser":
-		CmdSetUser(bot, m, cmdParts)
+		CmdSetUser(bot, server, m, cmdParts)
+	case "stats":
+		CmdStats(bot, m, cmdParts)
+	case "top":
+		CmdTopPlays(bot, m, cmdParts)
 	case "unset":
-		CmdUnsetUser(bot, m)
+		CmdUnsetUser(bot, m, cmdParts)
+	case "verify":
+		CmdVerify(bot, server, m, cmdParts)
 	case "vs":
 		CmdVersus(bot, m, cmdParts)
 	case "here":
@@ -134,18 +216,72 @@ func ready(bot *eb.Bot, r *discordgo.Ready) {
 	// Periodically set the bot status
 	go func() {
 		for {
-			bot.Session.UpdateStatus(0, ";help")
+			bot.Session.UpdateGameStatus(0, ";help")
 			<-time.After(1 * time.Hour)
 		}
 	}()
 
-	// Periodically check for recent plays
+	// Periodically check for recent plays and apply nickname updates that were held back
 	go func() {
 		for {
 			TrackAllRecentPlays(bot, defaultRecentPlayMinAcc)
+			SyncPendingNicknames(bot)
 			<-time.After(recentPlayInterval)
 		}
 	}()
+
+	// Periodically announce the winners of challenges that ended
+	go func() {
+		for {
+			AnnounceChallengeWinners(bot)
+			<-time.After(challengeCheckInterval)
+		}
+	}()
+
+	// Periodically check for scores in matches
+	go func() {
+		for {
+			CheckMatches(bot)
+			<-time.After(matchCheckInterval)
+		}
+	}()
+
+	// Periodically post any digests that are due
+	go func() {
+		for {
+			PostDigests(bot)
+			<-time.After(digestCheckInterval)
+		}
+	}()
+}
+
+// popFlag removes a flag (e.g. "-chart") from the command args, returning whether
+// the flag was present
+func popFlag(args []string, flag string) ([]string, bool) {
+	var result []string
+	found := false
+
+	for _, arg := range args {
+		if strings.ToLower(arg) == flag {
+			found = true
+		} else {
+			result = append(result, arg)
+		}
+	}
+
+	return result, found
+}
+
+// isServerAdmin checks if the author of the message is allowed to manage the server
+func isServerAdmin(bot *eb.Bot, m *discordgo.MessageCreate) bool {
+	perms, err := bot.Session.UserChannelPermissions(m.Author.ID, m.ChannelID)
+
+	if err != nil {
+		fmt.Println("Failed to look up permissions", m.Author.ID, err)
+		return false
+	}
+
+	return perms&discordgo.PermissionAdministrator != 0 || perms&discordgo.PermissionManageServer != 0
 }
 
 func parseMessageNoCmd(bot *eb.Bot, m *discordgo.MessageCreate) {
@@ -211,6 +347,7 @@ func handleScoreURLs(bot *eb.Bot, m *discordgo.MessageCreate) {
 	score.MinesHit = detail.MinesHit
 	score.Mods = detail.Mods
 	score.Date = detail.Date
+	score.Valid = detail.Valid
 	user, err := getUserOrCreate(bot, detail.User.Username, false)
 
 	if err != nil {
@@ -221,6 +358,10 @@ func handleScoreURLs(bot *eb.Bot, m *discordgo.MessageCreate) {
 		return
 	}
 
+	if err := saveScore(bot, score, user, false); err != nil {
+		fmt.Println("Failed to save score", score.Key, err)
+	}
+
 	embed, err := getPlaySummaryAsDiscordEmbed(bot, score, user)
 
 	if err != nil {
@@ -229,15 +370,15 @@ func handleScoreURLs(bot *eb.Bot, m *discordgo.MessageCreate) {
 	}
 
 	embed.Author.Name = "Played by " + user.Username
-	_, err = bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
 
-	if err != nil {
+	if err := sendScoreEmbed(bot, m.ChannelID, embed, score); err != nil {
 		fmt.Println(err)
 		return
 	}
 
 	server, _ := bot.Servers.Get(m.GuildID)
-	server.LastSongID.Int64 = int64(score.Song.ID)
-	server.LastSongID.Valid = true
-	bot.Servers.Save(server)
+
+	if err := setChannelSong(bot, server.ServerID, m.ChannelID, score.Song.ID); err != nil {
+		fmt.Println("Failed to set the current song", err)
+	}
 }
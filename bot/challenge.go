@@ -0,0 +1,450 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/etterna"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/Kangaroux/etternabot/util"
+	"github.com/bwmarrin/discordgo"
+	"github.com/lib/pq"
+)
+
+const (
+	challengeCheckInterval  = 5 * time.Minute    // How often to check for challenges that ended
+	defaultChallengeLength  = 7 * 24 * time.Hour // How long a challenge runs for by default
+	challengeStandingsCount = 10                 // Number of places to show in the standings
+)
+
+var (
+	reChallenge = regexp.MustCompile(`(?i)^(.+?)(?:\s+at\s+([\d.x,]+))?(?:\s+by\s+(acc|ssr))?(?:\s+in\s+(\d+[a-z]+))?(?:\s+for\s+(\d+[a-z]+))?$`)
+
+	challengeScoringNames = map[string]string{
+		model.ChallengeBestAccuracy: "best accuracy",
+		model.ChallengeBestSSR:      "best SSR",
+	}
+)
+
+// CmdChallenge creates, cancels and lists the server's score challenges. Scores are
+// submitted automatically by the recent plays tracker
+func CmdChallenge(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	if len(args) == 1 || strings.ToLower(args[1]) == "list" {
+		listChallenges(bot, m)
+		return
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "create":
+		createChallenge(bot, m, args[2:])
+	case "cancel":
+		cancelChallenge(bot, m, args[2:])
+	case "standings":
+		showChallengeStandings(bot, m, args[2:])
+	default:
+		bot.Session.ChannelMessageSend(m.ChannelID, "Usage: challenge [list | create <challenge> | cancel <id> | standings [id]]")
+	}
+}
+
+func createChallenge(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	if !isServerAdmin(bot, m) {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You need the Manage Server permission to do that.")
+		return
+	}
+
+	challenge, songQuery, err := parseChallenge(args, time.Now().UTC())
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	song, err := findSong(bot, songQuery)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if song == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Could not find a song with that name. Use the `song` command to look up its ID.")
+		return
+	}
+
+	challenge.ServerID = m.GuildID
+	challenge.ChannelID = m.ChannelID
+	challenge.SongID = song.EtternaID
+
+	if err := bot.Challenges.Save(challenge); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Created challenge #%d: %s. It starts <t:%d:R> and ends <t:%d:R>. "+
+		"Plays are entered automatically, and the winners will be announced in this channel.",
+		challenge.ID, describeChallenge(bot, challenge), challenge.StartsAt.Unix(), challenge.EndsAt.Unix()))
+}
+
+func cancelChallenge(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	if !isServerAdmin(bot, m) {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You need the Manage Server permission to do that.")
+		return
+	}
+
+	challenge, err := getServerChallenge(bot, m, args)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if challenge == nil {
+		return
+	}
+
+	if err := bot.Challenges.Delete(challenge.ID); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Cancelled challenge #%d.", challenge.ID))
+}
+
+func listChallenges(bot *eb.Bot, m *discordgo.MessageCreate) {
+	challenges, err := bot.Challenges.GetUpcoming(m.GuildID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if len(challenges) == 0 {
+		bot.Session.ChannelMessageSend(m.ChannelID, "There aren't any challenges running right now.")
+		return
+	}
+
+	description := ""
+	now := time.Now()
+
+	for _, c := range challenges {
+		status := fmt.Sprintf("ends <t:%d:R>", c.EndsAt.Unix())
+
+		if c.StartsAt.After(now) {
+			status = fmt.Sprintf("starts <t:%d:R>", c.StartsAt.Unix())
+		}
+
+		description += fmt.Sprintf("**#%d** %s — %s\n", c.ID, describeChallenge(bot, c), status)
+	}
+
+	bot.Session.ChannelMessageSendEmbed(m.ChannelID, &discordgo.MessageEmbed{
+		Color:       embedColor,
+		Title:       "Challenges",
+		Description: description,
+	})
+}
+
+func showChallengeStandings(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	challenge, err := getServerChallenge(bot, m, args)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if challenge == nil {
+		return
+	}
+
+	standings, users, err := getChallengeStandings(bot, challenge)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	bot.Session.ChannelMessageSendEmbed(m.ChannelID, getStandingsEmbed(bot, challenge, standings, users))
+}
+
+// getServerChallenge looks up the challenge with the ID in args, or the challenge that
+// ends next if there's no ID. If the challenge doesn't exist or isn't in this server,
+// a message is sent and nil is returned
+func getServerChallenge(bot *eb.Bot, m *discordgo.MessageCreate, args []string) (*model.Challenge, error) {
+	if len(args) == 0 {
+		challenges, err := bot.Challenges.GetUpcoming(m.GuildID)
+
+		if err != nil {
+			return nil, err
+		} else if len(challenges) == 0 {
+			bot.Session.ChannelMessageSend(m.ChannelID, "There aren't any challenges running right now.")
+			return nil, nil
+		}
+
+		return challenges[0], nil
+	}
+
+	id, err := strconv.ParseUint(strings.TrimPrefix(args[0], "#"), 10, 32)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "The challenge ID must be a number, e.g. `challenge standings 3`")
+		return nil, nil
+	}
+
+	challenge, err := bot.Challenges.Get(uint(id))
+
+	if err != nil {
+		return nil, err
+	} else if challenge == nil || challenge.ServerID != m.GuildID {
+		bot.Session.ChannelMessageSend(m.ChannelID, "There's no challenge with that ID in this server.")
+		return nil, nil
+	}
+
+	return challenge, nil
+}
+
+// parseChallenge parses a challenge in the form:
+//
+//	<song> [at <rate,...>] [by acc|ssr] [in <period>] [for <period>]
+//
+// e.g. "Ghost Rule at 1.1x,1.2x by ssr in 1d for 2w". The challenge starts after the
+// "in" period (now by default) and runs for the "for" period (a week by default).
+// The song name/ID is returned so it can be looked up
+func parseChallenge(args []string, now time.Time) (*model.Challenge, string, error) {
+	usage := errors.New("Usage: challenge create <song> [at <rate,...>] [by acc|ssr] [in <period>] [for <period>]")
+	match := reChallenge.FindStringSubmatch(strings.Join(args, " "))
+
+	if match == nil {
+		return nil, "", usage
+	}
+
+	challenge := &model.Challenge{
+		Rates:    pq.Float64Array{},
+		Scoring:  model.ChallengeBestAccuracy,
+		StartsAt: now,
+	}
+
+	if match[2] != "" {
+		for _, r := range strings.Split(match[2], ",") {
+			if r == "" {
+				continue
+			}
+
+			rate, err := parseRate(r)
+
+			if err != nil {
+				return nil, "", err
+			}
+
+			challenge.Rates = append(challenge.Rates, rate)
+		}
+	}
+
+	if match[3] != "" {
+		challenge.Scoring = strings.ToLower(match[3])
+	}
+
+	if match[4] != "" {
+		delay, ok := util.ParsePeriod(match[4])
+
+		if !ok {
+			return nil, "", errors.New("The start must be a period from now, e.g. `in 1d`")
+		}
+
+		challenge.StartsAt = now.Add(delay)
+	}
+
+	length := defaultChallengeLength
+
+	if match[5] != "" {
+		var ok bool
+
+		if length, ok = util.ParsePeriod(match[5]); !ok {
+			return nil, "", errors.New("The length must be a period, e.g. `for 7d`")
+		}
+	}
+
+	challenge.EndsAt = challenge.StartsAt.Add(length)
+
+	return challenge, strings.TrimSpace(match[1]), nil
+}
+
+// describeChallenge returns a short description of the challenge, e.g.
+// "Ghost Rule at 1.1x/1.2x (best accuracy)"
+func describeChallenge(bot *eb.Bot, c *model.Challenge) string {
+	name := fmt.Sprintf("song #%d", c.SongID)
+
+	if song, err := bot.Songs.Get(c.SongID); err == nil && song != nil {
+		name = fmt.Sprintf("[%s](%s/song/view/%d)", song.Name, bot.API.BaseURL(), c.SongID)
+	}
+
+	rates := "any rate"
+
+	if len(c.Rates) > 0 {
+		var r []string
+
+		for _, rate := range c.Rates {
+			r = append(r, formatRate(rate)+"x")
+		}
+
+		rates = strings.Join(r, "/")
+	}
+
+	return fmt.Sprintf("%s at %s (%s)", name, rates, challengeScoringNames[c.Scoring])
+}
+
+// getChallengeStandings returns each player's best score that was entered in the
+// challenge, along with the players who are registered in the challenge's server
+func getChallengeStandings(bot *eb.Bot, c *model.Challenge) ([]*model.Score, map[uint]*model.EtternaUser, error) {
+	scores, err := bot.Challenges.GetStandings(c)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	registered, err := bot.Users.GetRegisteredUsers(c.ServerID)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	users := make(map[uint]*model.EtternaUser)
+
+	for _, u := range registered {
+		users[u.ID] = u
+	}
+
+	return scores, users, nil
+}
+
+// getStandingsEmbed lists the standings of the challenge
+func getStandingsEmbed(bot *eb.Bot, c *model.Challenge, standings []*model.Score, users map[uint]*model.EtternaUser) *discordgo.MessageEmbed {
+	description := describeChallenge(bot, c) + "\n\n"
+
+	if len(standings) == 0 {
+		description += "Nobody has entered yet."
+	}
+
+	for i, s := range standings {
+		if i == challengeStandingsCount {
+			description += fmt.Sprintf("…and %d more\n", len(standings)-challengeStandingsCount)
+			break
+		}
+
+		name := fmt.Sprintf("user #%d", s.UserID)
+
+		if u, ok := users[s.UserID]; ok {
+			name = u.Username
+		}
+
+		description += fmt.Sprintf("**%d.** %s — %.2f%% at %sx (**%.2f**)\n",
+			i+1, name, s.Accuracy, formatRate(s.Rate), s.MSDOverall)
+	}
+
+	return &discordgo.MessageEmbed{
+		Color:       embedColor,
+		Title:       fmt.Sprintf("Challenge #%d standings", c.ID),
+		Description: description,
+		Timestamp:   c.EndsAt.Format(time.RFC3339),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Ends",
+		},
+	}
+}
+
+// enterChallenges submits the play to any of the server's running challenges that it's
+// eligible for. The play should already be saved to the score history. Returns a
+// summary of the challenges that were entered
+func enterChallenges(bot *eb.Bot, serverID string, user *model.EtternaUser, score *etterna.Score) string {
+	if !score.Valid {
+		return ""
+	}
+
+	challenges, err := bot.Challenges.GetUpcoming(serverID)
+
+	if err != nil {
+		fmt.Println("Failed to look up challenges", serverID, err)
+		return ""
+	}
+
+	entered := ""
+
+	for _, c := range challenges {
+		if c.SongID != score.Song.ID ||
+			!c.AllowsRate(score.Rate) ||
+			score.Date.Before(c.StartsAt) ||
+			!score.Date.Before(c.EndsAt) {
+			continue
+		}
+
+		ok, err := bot.Challenges.AddEntry(c.ID, score.Key)
+
+		if err != nil {
+			fmt.Println("Failed to enter challenge", c.ID, score.Key, err)
+			continue
+		} else if !ok {
+			continue
+		}
+
+		standings, err := bot.Challenges.GetStandings(c)
+
+		if err != nil {
+			fmt.Println("Failed to look up challenge standings", c.ID, err)
+			continue
+		}
+
+		for i, s := range standings {
+			if s.UserID == user.ID {
+				entered += fmt.Sprintf("🏁 **Challenge #%d:** currently in place #%d of %d\n", c.ID, i+1, len(standings))
+				break
+			}
+		}
+	}
+
+	return strings.TrimSuffix(entered, "\n")
+}
+
+// AnnounceChallengeWinners posts the final standings of every challenge that ended
+func AnnounceChallengeWinners(bot *eb.Bot) {
+	// Give the tracker a chance to pick up any plays from right before the end
+	challenges, err := bot.Challenges.GetUnannounced(time.Now().UTC().Add(-recentPlayInterval))
+
+	if err != nil {
+		fmt.Println("Failed to look up ended challenges", err)
+		return
+	}
+
+	for _, c := range challenges {
+		standings, users, err := getChallengeStandings(bot, c)
+
+		if err != nil {
+			fmt.Println("Failed to look up challenge standings", c.ID, err)
+			continue
+		}
+
+		embed := getStandingsEmbed(bot, c, standings, users)
+		embed.Title = fmt.Sprintf("Challenge #%d is over!", c.ID)
+		embed.Footer.Text = "Ended"
+		content := ""
+
+		// Mention the winner if they're still registered in the server
+		if len(standings) > 0 {
+			content = "🏆 Congratulations to the winner!"
+
+			if u, ok := users[standings[0].UserID]; ok {
+				if discordID, err := bot.Users.GetRegisteredDiscordUserID(c.ServerID, u.Username); err == nil && discordID != "" {
+					content = fmt.Sprintf("🏆 Congratulations to the winner, <@%s>!", discordID)
+				}
+			}
+		}
+
+		if _, err := bot.Session.ChannelMessageSendComplex(c.ChannelID, &discordgo.MessageSend{
+			Content: content,
+			Embed:   embed,
+		}); err != nil {
+			fmt.Println("Failed to announce challenge winners", c.ID, err)
+		}
+
+		now := time.Now().UTC()
+		c.AnnouncedAt = &now
+
+		if err := bot.Challenges.Save(c); err != nil {
+			fmt.Println("Failed to save challenge", c.ID, err)
+		}
+	}
+}
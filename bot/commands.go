@@ -5,24 +5,36 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	eb "github.com/Kangaroux/etternabot"
 	"github.com/Kangaroux/etternabot/etterna"
 	"github.com/Kangaroux/etternabot/model"
 	"github.com/Kangaroux/etternabot/util"
 	"github.com/bwmarrin/discordgo"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultProgressPeriod = 30 * 24 * time.Hour // How far back the progress command looks by default
 )
 
 var (
 	reCompareRate = regexp.MustCompile(`compare@(\d*\.?\d*)`)
 )
 
-// CmdCompare gets the user's best score for the last song posted in the server
+// CmdCompare gets the user's best score for the current song in the channel, or for
+// the song and rate of the score the command replied to
 func CmdCompare(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate, args []string) {
 	var err error
 	var user *model.EtternaUser
 
-	if !server.LastSongID.Valid {
+	songID, rate, ok, err := getTargetSong(bot, server, m)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if !ok {
 		bot.Session.ChannelMessageSend(m.ChannelID, "No scores to compare to.")
 		return
 	}
@@ -43,7 +55,7 @@ func CmdCompare(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCr
 	}
 
 	bot.Session.ChannelTyping(m.ChannelID)
-	song, err := getSongOrCreate(bot, int(server.LastSongID.Int64))
+	song, err := getSongOrCreate(bot, songID)
 
 	if err != nil {
 		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
@@ -60,15 +72,19 @@ func CmdCompare(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCr
 
 	var score *etterna.Score
 
-	// Find the first matching score
+	// Find the first matching score. If the command replied to a score, only scores
+	// at the same rate match
 	for i, s := range scores {
-		if s.Song.ID == int(server.LastSongID.Int64) {
+		if s.Song.ID == songID && (rate == 0 || s.Rate == rate) {
 			score = &scores[i]
 			break
 		}
 	}
 
-	if len(scores) == 0 || score == nil {
+	if score == nil && rate > 0 {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("%s has no scores on '%s' at %s", user.Username, song.Name, formatRate(rate)))
+		return
+	} else if len(scores) == 0 || score == nil {
 		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("%s has no scores on '%s'", user.Username, song.Name))
 		return
 	}
@@ -86,6 +102,10 @@ func CmdCompare(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCr
 	score.Mods = details.Mods
 	score.MinesHit = details.MinesHit
 
+	if err := saveScore(bot, score, user, false); err != nil {
+		fmt.Println("Failed to save score", score.Key, err)
+	}
+
 	embed, err := getPlaySummaryAsDiscordEmbed(bot, score, user)
 	embed.Author.Name = "Played by " + user.Username
 
@@ -94,10 +114,12 @@ func CmdCompare(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCr
 		return
 	}
 
-	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+	if err := sendScoreEmbed(bot, m.ChannelID, embed, score); err != nil {
+		fmt.Println("Failed to send score", score.Key, err)
+	}
 }
 
-// CmdCompareRate gets the user's best score for the last song posted in the server
+// CmdCompareRate gets the user's best score for the current song in the channel
 // at a specific rate
 func CmdCompareRate(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate, args []string) {
 	var err error
@@ -112,26 +134,19 @@ func CmdCompareRate(bot *eb.Bot, server *model.DiscordServer, m *discordgo.Messa
 		return
 	}
 
-	rate, _ := strconv.ParseFloat(match[1], 64)
+	rate, err := parseRate(match[1])
 
-	if rate < 0.7 || rate > 3.0 {
-		bot.Session.ChannelMessageSend(m.ChannelID, "Rate must be between 0.7 and 3.0.")
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
 		return
 	}
 
-	split := strings.Split(args[0], ".")
-
-	// Has a decimal part
-	if len(split) == 2 {
-		// Verify the decimal part is not longer than 2 digits and that if it is 2 digits the
-		// leading number is a 0 or a 5.
-		if len(split[1]) > 2 || (len(split[1]) == 2 && split[1][1] != '0' && split[1][1] != '5') {
-			bot.Session.ChannelMessageSend(m.ChannelID, "Rate must be in 0.05 increments.")
-			return
-		}
-	}
+	songID, _, ok, err := getTargetSong(bot, server, m)
 
-	if !server.LastSongID.Valid {
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if !ok {
 		bot.Session.ChannelMessageSend(m.ChannelID, "No scores to compare to.")
 		return
 	}
@@ -152,7 +167,7 @@ func CmdCompareRate(bot *eb.Bot, server *model.DiscordServer, m *discordgo.Messa
 	}
 
 	bot.Session.ChannelTyping(m.ChannelID)
-	song, err := getSongOrCreate(bot, int(server.LastSongID.Int64))
+	song, err := getSongOrCreate(bot, songID)
 
 	if err != nil {
 		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
@@ -172,7 +187,7 @@ func CmdCompareRate(bot *eb.Bot, server *model.DiscordServer, m *discordgo.Messa
 
 	// Find the first matching score at this rate
 	for i, s := range scores {
-		if s.Song.ID == int(server.LastSongID.Int64) {
+		if s.Song.ID == songID {
 			hasAnyScore = true
 
 			if s.Rate == rate {
@@ -182,13 +197,7 @@ func CmdCompareRate(bot *eb.Bot, server *model.DiscordServer, m *discordgo.Messa
 		}
 	}
 
-	rateStr := fmt.Sprintf("%.2f", rate)
-	length := len(rateStr)
-
-	// Remove a trailing zero if it exists (0.80 -> 0.8, 1.00 -> 1.0)
-	if rateStr[length-1] == '0' {
-		rateStr = rateStr[:length-1]
-	}
+	rateStr := formatRate(rate)
 
 	if len(scores) == 0 || !hasAnyScore {
 		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("%s has no scores on '%s'", user.Username, song.Name))
@@ -211,6 +220,10 @@ func CmdCompareRate(bot *eb.Bot, server *model.DiscordServer, m *discordgo.Messa
 	score.Mods = details.Mods
 	score.MinesHit = details.MinesHit
 
+	if err := saveScore(bot, score, user, false); err != nil {
+		fmt.Println("Failed to save score", score.Key, err)
+	}
+
 	embed, err := getPlaySummaryAsDiscordEmbed(bot, score, user)
 	embed.Author.Name = "Played by " + user.Username
 
@@ -219,81 +232,285 @@ func CmdCompareRate(bot *eb.Bot, server *model.DiscordServer, m *discordgo.Messa
 		return
 	}
 
-	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+	if err := sendScoreEmbed(bot, m.ChannelID, embed, score); err != nil {
+		fmt.Println("Failed to send score", score.Key, err)
+	}
 }
 
-// CmdHelp prints some help text for the user
-func CmdHelp(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate) {
+// The help text for each command. The name of the command should be in bold at the
+// start of the field name so it can be looked up with "help <command>"
+var helpFields = []*discordgo.MessageEmbedField{
+	&discordgo.MessageEmbedField{
+		Name:   "**help** [command]",
+		Value:  "Lists the commands, or shows the help text for one of them. Cool.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**serverlb** [skillset]",
+		Value:  "Lists the players registered in this server ordered by their rating in a skillset (overall by default).",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**songlb** [rate] [song name or ID]",
+		Value:  "Ranks the best scores of everyone in this server on a song (the last song posted in this channel by default). If a rate is given (e.g. `1.2x`), only scores at that rate are ranked, by accuracy.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**setuser** <username> [-here] [-verify]",
+		Value:  "Links an Etterna Online user to you in every server you share with the bot. This will cause your recent plays to be tracked automatically. Add `-here` to only register in this server, or `-verify` to prove you own the account (some servers require this).",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**unset** [-everywhere]",
+		Value:  "Unregisters you in this server. Add `-everywhere` to unlink you from your Etterna Online user in every server. Your recent plays will no longer be tracked.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**badges** [username]",
+		Value:  "Lists the achievements you or someone else has unlocked. Achievements are unlocked by tracked plays, e.g. your first AAAA or 100 AAAs.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**challenge** [list | create <challenge> | cancel <id> | standings [id]]",
+		Value:  "Runs score challenges on a song, e.g. `challenge create Ghost Rule at 1.1x,1.2x by ssr in 1d for 7d` (ranked by acc by default, starting now and running for a week). Plays are entered automatically and the winners are announced when it ends. Creating and cancelling requires the Manage Server permission.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**compare** [username]",
+		Value:  "Compares you or someone else's best score on the last song posted in this channel. Reply to a score with this command to compare on that song and rate instead.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**compare**@<rate> [username]",
+		Value:  "Compares you or someone else's best score on the last song posted in this channel at a specific rate. The rate must be a number between 0.7 and 3.0, and it must be in 0.05 increments.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**digest** [off | daily | weekly | here | sections <section...> | preview]",
+		Value:  "Shows or changes the daily/weekly digest of the biggest gains, top plays, most active players, new personal bests and rank movers in this server. Sections: gains, plays, active, pbs, ranks. Changing it requires the Manage Server permission.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**goal** [list | add <goal> | remove <number> | history]",
+		Value:  "Manages your goals, e.g. `goal add overall 25`, `goal add AAA on <song> at 1.2x` or `goal add top 500 in stream`. Completed goals are announced with your play in the scores channel.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**graph** [username] [skillset] [period] [vs <username>]",
+		Value:  "Draws a graph of your or someone else's ratings over time (the last 90 days by default). Use `vs <username>` to compare with another player.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**h2h** <username> [username]",
+		Value:  "Compares two players' scores on the songs they've both played at the same rate: wins, losses, ties, the biggest margins and the average difference in each skillset. The first run for a player can take a while since their scores need to be looked up.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**match** [status | create | pool | ban | pick | cancel]",
+		Value:  "Runs a 1v1 match in this channel, e.g. `match create @player1 @player2 bo5`. An admin adds maps with `match pool add <song> [at <rate>]`, then the players take turns with `match ban <number>` and `match pick <number>`. Scores on the picked map are detected automatically and the higher accuracy wins the round.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**members** [list | register | unregister | transfer | prune]",
+		Value:  "Manages who is registered in this server. Use `members register <member> <username>` or `members unregister <member>` to fix a registration, `members transfer <username> <member>` to move an Etterna user to someone else, and `members prune` to remove members who left. Requires the Manage Server permission.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**milestones** [rank...]",
+		Value:  "Shows or sets the global ranks that are called out when a player reaches them (e.g. `milestones 1000 500 100`). Use `milestones off` to disable. Setting them requires the Manage Server permission.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**nickname** [template | off | sync]",
+		Value:  "Shows or sets the template for registered players' nicknames, which are updated as their ratings change. Templates can use {discord}, {username}, {overall}, {rank}, {country} and {flag} (e.g. `nickname {flag} {discord} [{overall}]`). Use `nickname off` to disable. Requires the Manage Server permission.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**pick** <number>",
+		Value:  "Makes one of the results from the last `song` search the current song for `compare` and `songlb`.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**profile** [username] [-chart]",
+		Value:  "Gets a summary of your current ranks and ratings. Add `-chart` to include a chart of your skillsets.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**progress** [username] [period]",
+		Value:  "Shows how much you or someone else gained in each skillset over a period of time, e.g. `7d`, `2w`, `1m` or `1y`. Defaults to the last 30 days.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**recent** [username]",
+		Value:  "Gets a summary of your latest play, or the play of whichever player you specify.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**recommend** [skillset] [difficulty offset]",
+		Value:  "Suggests charts you haven't played or AAA'd yet that are rated just above your rating in a skillset (overall by default), e.g. `recommend stream +0.5`. Ratings are estimated from other players' scores.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**rival** [list | add <username> | remove <username>]",
+		Value:  "Manages your rivals. You'll get a DM when a rival overtakes you in a skillset or beats your best score on a song you've both played at the same rate.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**roles** [list | add <role> <threshold> | remove <role> | sync]",
+		Value:  "Lists or changes the roles players are given for reaching a rating or rank, e.g. `roles add @Overall25 overall 25` or `roles add @Top1000 top 1000`. Roles are updated whenever a player's ratings are looked up, and `roles sync` updates everyone now. Changing them requires the Manage Server permission, and the bot needs the Manage Roles permission.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**song** <name, artist or ID>",
+		Value:  "Searches for songs by name or artist, even if the name is misspelled. Use `pick` to choose one of the results.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**stats** [username] [-heatmap]",
+		Value:  "Shows how much you or someone else has been playing, from tracked plays: daily streaks, sessions, plays per day and week, notes hit and favorite rates. Add `-heatmap` to include a calendar of your activity.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**top** [username] [skillset] [count] [filters]",
+		Value:  "Lists your or someone else's best plays by nerfed rating, or by a skillset's rating. Plays can be filtered by rate or accuracy, e.g. `top stream rate>=1.2 acc>=97`.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**verify** [cancel | required on/off]",
+		Value:  "Finishes linking your Etterna Online user once you've put your verification code in your profile's about me. Admins can use `verify required on` to make everyone verify their account when they use `setuser`.",
+		Inline: false,
+	},
+
+	&discordgo.MessageEmbedField{
+		Name:   "**vs** <username> [username] [-chart]",
+		Value:  "Compares two user's profiles. If you only specify one username, that user's profile will be compared to yours. Add `-chart` to include a chart comparing your skillsets.",
+		Inline: false,
+	},
+}
 
+// CmdHelp lists the commands, or prints the help text for a specific command
+func CmdHelp(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate, args []string) {
 	prefix := server.CommandPrefix
 
+	if len(args) > 1 {
+		name := "**" + strings.TrimPrefix(strings.ToLower(args[1]), prefix) + "**"
+		embed := &discordgo.MessageEmbed{Color: embedColor}
+
+		for _, f := range helpFields {
+			if strings.HasPrefix(f.Name, name) {
+				embed.Fields = append(embed.Fields, f)
+			}
+		}
+
+		if len(embed.Fields) == 0 {
+			bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Unrecognized command '%s'.", args[1]))
+			return
+		}
+
+		bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+		return
+	}
+
+	var commands []string
+
+	for _, f := range helpFields {
+		commands = append(commands, "➤ "+f.Name)
+	}
+
 	embed := &discordgo.MessageEmbed{
 		Title: "EtternaBot Help",
 		Description: "I'm a bot for tracking Etterna Online plays. https://etternaonline.com\nFor commands, " +
-			"use this prefix: `" + prefix + "`\n\nI can also post score summaries if you send a link to a score.",
-
-		Fields: []*discordgo.MessageEmbedField{
-
-			&discordgo.MessageEmbedField{
-				Name:   "**help**",
-				Value:  "Shows this help text. Cool.",
-				Inline: false,
-			},
-
-			&discordgo.MessageEmbedField{
-				Name:   "**setuser** <username>",
-				Value:  "Links an Etterna Online user to you. This will cause your recent plays to be tracked automatically.",
-				Inline: false,
-			},
-
-			&discordgo.MessageEmbedField{
-				Name:   "**unset**",
-				Value:  "Unlinks you from any Etterna Online users. Your recent plays will no longer be tracked.",
-				Inline: false,
-			},
-
-			&discordgo.MessageEmbedField{
-				Name:   "**compare** [username]",
-				Value:  "Compares you or someone else's best score on the last posted song.",
-				Inline: false,
-			},
-
-			&discordgo.MessageEmbedField{
-				Name:   "**compare**@<rate> [username]",
-				Value:  "Compares you or someone else's best score on the last posted song at a specific rate. The rate must be a number between 0.7 and 3.0, and it must be in 0.05 increments.",
-				Inline: false,
-			},
-
-			&discordgo.MessageEmbedField{
-				Name:   "**profile**",
-				Value:  "Gets a summary of your current ranks and ratings.",
-				Inline: false,
-			},
-
-			&discordgo.MessageEmbedField{
-				Name:   "**recent** [username]",
-				Value:  "Gets a summary of your latest play, or the play of whichever player you specify.",
-				Inline: false,
-			},
-
-			&discordgo.MessageEmbedField{
-				Name:   "**vs** <username> [username]",
-				Value:  "Compares two user's profiles. If you only specify one username, that user's profile will be compared to yours.",
-				Inline: false,
-			},
-		},
-
+			"use this prefix: `" + prefix + "`\n\nI can also post score summaries if you send a link to a score.\n\n" +
+			strings.Join(commands, "\n") + "\n\nUse `" + prefix + "help <command>` to learn more about a command.",
 		Color: embedColor,
 	}
 
 	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
 }
 
+// CmdSetRankMilestones shows or sets the global ranks that are called out when a
+// tracked player reaches them
+func CmdSetRankMilestones(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate, args []string) {
+	if len(args) == 1 {
+		if len(server.RankMilestones) == 0 {
+			bot.Session.ChannelMessageSend(m.ChannelID, "Rank milestones are disabled.")
+			return
+		}
+
+		var ranks []string
+
+		for _, r := range server.RankMilestones {
+			ranks = append(ranks, fmt.Sprintf("#%d", r))
+		}
+
+		bot.Session.ChannelMessageSend(m.ChannelID, "Rank milestones: "+strings.Join(ranks, ", "))
+		return
+	}
+
+	if !isServerAdmin(bot, m) {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You need the Manage Server permission to do that.")
+		return
+	}
+
+	milestones := pq.Int64Array{}
+
+	if strings.ToLower(args[1]) != "off" {
+		for _, arg := range args[1:] {
+			rank, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 32)
+
+			if err != nil || rank < 1 {
+				bot.Session.ChannelMessageSend(m.ChannelID, "Usage: milestones <rank> [rank...]")
+				return
+			}
+
+			milestones = append(milestones, rank)
+		}
+	}
+
+	server.RankMilestones = milestones
+
+	if err := bot.Servers.Save(server); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, "Rank milestones updated.")
+}
+
 // CmdProfile displays a user's current rank and ratings
 func CmdProfile(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
 	var err error
 	var user *model.EtternaUser
 
+	args, withChart := popFlag(args, "-chart")
+
 	if len(args) == 1 {
 		user, err = bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID)
 	} else if len(args) > 1 {
@@ -342,6 +559,115 @@ func CmdProfile(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
 		},
 	}
 
+	if withChart {
+		bot.Session.ChannelTyping(m.ChannelID)
+
+		if err := sendEmbedWithImage(bot, m.ChannelID, embed, "skillsets.png", getSkillsetRadarChart(bot, user)); err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		}
+
+		return
+	}
+
+	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+}
+
+// CmdProgress shows how much a user's ratings and ranks changed over a period of time
+func CmdProgress(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	var err error
+	var user *model.EtternaUser
+
+	period := defaultProgressPeriod
+	args = args[1:]
+
+	// The period is always the last argument if it's given
+	if len(args) > 0 {
+		if p, ok := util.ParsePeriod(args[len(args)-1]); ok {
+			period = p
+			args = args[:len(args)-1]
+		}
+	}
+
+	if len(args) == 0 {
+		user, err = bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID)
+	} else {
+		user, err = getUserOrCreate(bot, args[0], false)
+	}
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if user == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You are not registered with an Etterna user. "+
+			"Please register using the `setuser` command, or specify a user: progress <username> [period]")
+		return
+	}
+
+	bot.Session.ChannelTyping(m.ChannelID)
+
+	now := time.Now().UTC()
+	start := now.Add(-period)
+	since := ""
+	before, err := bot.Users.GetRatingAt(user.ID, start)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	// We don't have history going back that far, use the oldest snapshot we have
+	if before == nil {
+		history, err := bot.Users.GetRatingHistory(user.ID, start, now)
+
+		if err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		} else if len(history) == 0 {
+			bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("There is no rating history for %s yet.", user.Username))
+			return
+		}
+
+		before = history[0]
+		since = fmt.Sprintf(" (tracked since %s)", before.CreatedAt.Format("Jan 2, 2006"))
+	}
+
+	// Like the profile command, the latest info is shown but not cached so the
+	// recent plays tracker can still show the gains
+	if err := getLatestUserInfo(bot, user); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	var description string
+
+	for _, ss := range etterna.Skillsets {
+		oldMSD := before.MSD().Get(ss)
+		newMSD := user.MSD().Get(ss)
+		oldRank := before.Rank().Get(ss)
+		newRank := user.Rank().Get(ss)
+
+		description += fmt.Sprintf("➤ **%s:** %.2f → %.2f (%+.2f)", ss, oldMSD, newMSD, newMSD-oldMSD)
+
+		if oldRank > 0 && oldRank != newRank {
+			description += fmt.Sprintf(" #%d → #%d", oldRank, newRank)
+		}
+
+		description += "\n"
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Description: description,
+		Color:       embedColor,
+		Author: &discordgo.MessageEmbedAuthor{
+			IconURL: "https://i.imgur.com/HwIkGCk.png",
+			Name:    fmt.Sprintf("%s's progress over the last %s%s", user.Username, util.FormatPeriod(period), since),
+			URL:     bot.API.BaseURL() + "/user/" + user.Username,
+		},
+		Thumbnail: &discordgo.MessageEmbedThumbnail{
+			URL: bot.API.BaseURL() + "/avatars/" + user.Avatar,
+		},
+	}
+
 	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
 }
 
@@ -371,6 +697,13 @@ func CmdRecentPlay(bot *eb.Bot, server *model.DiscordServer, m *discordgo.Messag
 	if err != nil {
 		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
 		return
+	} else if score == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("%s has no recent plays.", user.Username))
+		return
+	}
+
+	if err := saveScore(bot, score, user, false); err != nil {
+		fmt.Println("Failed to save score", score.Key, err)
 	}
 
 	embed, err := getPlaySummaryAsDiscordEmbed(bot, score, user)
@@ -380,12 +713,13 @@ func CmdRecentPlay(bot *eb.Bot, server *model.DiscordServer, m *discordgo.Messag
 		return
 	}
 
-	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
-
-	server.LastSongID.Int64 = int64(score.Song.ID)
-	server.LastSongID.Valid = true
+	if err := sendScoreEmbed(bot, m.ChannelID, embed, score); err != nil {
+		fmt.Println("Failed to send score", score.Key, err)
+	}
 
-	bot.Servers.Save(server)
+	if err := setChannelSong(bot, server.ServerID, m.ChannelID, score.Song.ID); err != nil {
+		fmt.Println("Failed to set the current song", err)
+	}
 }
 
 // CmdSetScoresChannel sets which discord channel the bot should post scores in
@@ -400,13 +734,19 @@ func CmdSetScoresChannel(bot *eb.Bot, server *model.DiscordServer, m *discordgo.
 	}
 }
 
-// CmdSetUser links a discord user with an etterna user. Only one discord user
-// can be linked to a given etterna user at a time in a server. Likewise, discord
-// users can only be linked to one etterna user at a time in a server.
-func CmdSetUser(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+// CmdSetUser links a discord user with an etterna user in every server they share
+// with the bot, or with -here, only in this server. Only one discord user can be
+// registered as a given etterna user at a time in a server. Likewise, discord users
+// can only be registered as one etterna user at a time in a server. If the server
+// requires verification (or the user asks for it with -verify), the user must prove
+// they own the account with the verify command before they're registered.
+func CmdSetUser(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate, args []string) {
+	args, withVerify := popFlag(args, "-verify")
+	args, here := popFlag(args, "-here")
+
 	if len(args) < 2 {
 		bot.Session.ChannelMessageSend(m.ChannelID,
-			"Usage: setuser <username>")
+			"Usage: setuser <username> [-here] [-verify]")
 		return
 	}
 
@@ -423,68 +763,97 @@ func CmdSetUser(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
 			fmt.Sprintf("You are already registered as '%s'.", username))
 		return
 	} else if discordID != "" {
-		bot.Session.ChannelMessageSend(m.ChannelID,
-			fmt.Sprintf("Another user is already registered as '%s'.", username))
-		return
+		unverified, err := hasUnverifiedLink(bot, discordID, username)
+
+		if err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		} else if !unverified {
+			bot.Session.ChannelMessageSend(m.ChannelID,
+				fmt.Sprintf("Another user is already registered as '%s'.", username))
+			return
+		}
+
+		// The other user never verified their link, so it can be taken over by
+		// proving ownership of the account
+		withVerify = true
 	}
 
-	// Get the etterna user registered with this discord user
-	user, err := bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID)
+	// Look up the etterna user with that username
+	user, err := getUserOrCreate(bot, username, false)
 
 	if err != nil {
 		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
 		return
 	}
 
-	if user != nil {
-		bot.Session.ChannelMessageSend(m.ChannelID,
-			"You are already registered as another user. Use the 'unset' "+
-				"command first and try again.")
-		return
-	}
-
-	// The discord user is not associated with any etterna users, look up
-	// the etterna user with that username
-	user, err = getUserOrCreate(bot, username, false)
+	link, err := bot.Users.GetLink(m.Author.ID)
 
 	if err != nil {
 		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
 		return
 	}
 
-	ok, err := bot.Users.Register(user.Username, m.GuildID, m.Author.ID)
+	verify := server.RequireVerification || withVerify
+	sameLink := link != nil && strings.EqualFold(link.Username, user.Username)
 
-	if err != nil {
-		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+	// The user is linked to this etterna user but opted out of it in this server, so
+	// registering here again doesn't need to be verified unless the link wasn't
+	if sameLink && (link.Verified || !verify) {
+		registerHere(bot, m, user.Username)
+		return
+	} else if here {
+		if verify {
+			startVerification(bot, m, user.Username, true)
+		} else {
+			registerHere(bot, m, user.Username)
+		}
+
+		return
+	} else if link != nil && !sameLink {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("You are linked to '%s' in every server. Use "+
+			"`unset -everywhere` first and try again, or use `setuser %s -here` to only change it in this server.",
+			link.Username, user.Username))
 		return
 	}
 
-	if !ok {
-		// This can only happen due to a data race, still worth checking though
-		bot.Session.ChannelMessageSend(m.ChannelID,
-			"You are currently registered as another user. Use the 'unset' command "+
-				"first and try again.")
-	} else {
-		bot.Session.ChannelMessageSend(m.ChannelID,
-			fmt.Sprintf("Success! You are now registered as '%s'.", user.Username))
+	if verify {
+		startVerification(bot, m, user.Username, false)
+		return
 	}
+
+	linkUser(bot, m, user.Username, false)
 }
 
-// CmdUnsetUser unregisters the given discord user from an etterna user
-func CmdUnsetUser(bot *eb.Bot, m *discordgo.MessageCreate) {
-	ok, err := bot.Users.Unregister(m.GuildID, m.Author.ID)
+// CmdUnsetUser unregisters the given discord user from an etterna user in this server,
+// or with -everywhere, removes their link in every server
+func CmdUnsetUser(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	var ok bool
+	var err error
+
+	_, everywhere := popFlag(args, "-everywhere")
+
+	if everywhere {
+		ok, err = bot.Users.Unlink(m.Author.ID)
+	} else {
+		ok, err = bot.Users.Unregister(m.GuildID, m.Author.ID)
+	}
 
 	if err != nil {
 		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
 		return
 	}
 
-	if ok {
+	if !ok {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You are not registered to an etterna user.")
+	} else if everywhere {
 		bot.Session.ChannelMessageSend(m.ChannelID,
-			"Success! You are no longer registered. Use the setuser command to register "+
+			"Success! You are no longer registered in any server. Use the setuser command to register "+
 				"as another user.")
 	} else {
-		bot.Session.ChannelMessageSend(m.ChannelID, "You are not registered to an etterna user.")
+		bot.Session.ChannelMessageSend(m.ChannelID,
+			"Success! You are no longer registered in this server. Use the setuser command to register "+
+				"as another user, or `unset -everywhere` to unregister in every server.")
 	}
 }
 
@@ -493,6 +862,8 @@ func CmdVersus(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
 	var err error
 	var user1, user2 *model.EtternaUser
 
+	args, withChart := popFlag(args, "-chart")
+
 	if len(args) == 1 {
 		bot.Session.ChannelMessageSend(m.ChannelID,
 			"Usage: vs <username> [username]")
@@ -567,5 +938,15 @@ func CmdVersus(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
 		},
 	}
 
+	if withChart {
+		bot.Session.ChannelTyping(m.ChannelID)
+
+		if err := sendEmbedWithImage(bot, m.ChannelID, embed, "versus.png", getSkillsetRadarChart(bot, user1, user2)); err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		}
+
+		return
+	}
+
 	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
 }
@@ -0,0 +1,392 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/Kangaroux/etternabot/util"
+	"github.com/bwmarrin/discordgo"
+	"github.com/lib/pq"
+)
+
+const (
+	digestCheckInterval = 10 * time.Minute // How often to check if any digests are due
+	digestTopCount      = 5                // Number of entries to show in each digest section
+)
+
+// The sections that can be included in a digest, in the order they're shown
+const (
+	digestGains  = "gains"  // Players who gained the most overall rating
+	digestPlays  = "plays"  // Highest rated plays
+	digestActive = "active" // Players with the most plays
+	digestPBs    = "pbs"    // Plays that beat a previous best on the same song and rate
+	digestRanks  = "ranks"  // Players who climbed the most global ranks
+)
+
+var (
+	digestSections        = []string{digestGains, digestPlays, digestActive, digestPBs, digestRanks}
+	defaultDigestSections = pq.StringArray(digestSections)
+
+	digestSectionTitles = map[string]string{
+		digestGains:  "📈 Biggest gains",
+		digestPlays:  "🔥 Top plays",
+		digestActive: "🎮 Most active",
+		digestPBs:    "⭐ New personal bests",
+		digestRanks:  "🏅 Rank movers",
+	}
+)
+
+// CmdDigest shows or changes the server's digest settings. The digest is a summary
+// of what the server's players did over the last day or week
+func CmdDigest(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate, args []string) {
+	usage := "Usage: digest [off | daily | weekly | here | sections <section...> | preview]"
+
+	if len(args) == 1 {
+		showDigestSettings(bot, server, m)
+		return
+	}
+
+	if !isServerAdmin(bot, m) {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You need the Manage Server permission to do that.")
+		return
+	}
+
+	switch strings.ToLower(args[1]) {
+	case model.DigestOff, model.DigestDaily, model.DigestWeekly:
+		frequency := strings.ToLower(args[1])
+
+		// Start counting from now so the first digest covers a full period
+		if server.DigestFrequency != frequency {
+			now := time.Now().UTC()
+			server.LastDigestAt = &now
+		}
+
+		server.DigestFrequency = frequency
+
+	case "here":
+		server.DigestChannelID.String = m.ChannelID
+		server.DigestChannelID.Valid = true
+
+	case "sections":
+		sections, ok := parseDigestSections(args[2:])
+
+		if !ok {
+			bot.Session.ChannelMessageSend(m.ChannelID, "Usage: digest sections <section...>\nSections: "+
+				strings.Join(digestSections, ", "))
+			return
+		}
+
+		server.DigestSections = sections
+
+	case "preview":
+		period := digestPeriod(server.DigestFrequency)
+		embed, err := getDigestEmbed(bot, server, time.Now().UTC().Add(-period), period)
+
+		if err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		}
+
+		bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+		return
+
+	default:
+		bot.Session.ChannelMessageSend(m.ChannelID, usage)
+		return
+	}
+
+	if err := bot.Servers.Save(server); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	showDigestSettings(bot, server, m)
+}
+
+func showDigestSettings(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate) {
+	if server.DigestFrequency == model.DigestOff {
+		bot.Session.ChannelMessageSend(m.ChannelID, "The digest is off. Use `digest daily` or `digest weekly` to turn it on.")
+		return
+	}
+
+	channel := "the scores channel"
+
+	if server.DigestChannelID.Valid {
+		channel = "<#" + server.DigestChannelID.String + ">"
+	} else if !server.ScoreChannelID.Valid {
+		channel = "no channel (use `digest here` to pick one)"
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("The %s digest is posted in %s with these sections: %s",
+		server.DigestFrequency, channel, strings.Join(server.DigestSections, ", ")))
+}
+
+// parseDigestSections parses a list of section names. Returns false if any of the
+// names aren't a section
+func parseDigestSections(args []string) (pq.StringArray, bool) {
+	sections := pq.StringArray{}
+
+	for _, arg := range args {
+		name := strings.ToLower(strings.Trim(arg, ","))
+
+		if name == "" {
+			continue
+		} else if _, ok := digestSectionTitles[name]; !ok {
+			return nil, false
+		}
+
+		sections = append(sections, name)
+	}
+
+	return sections, len(sections) > 0
+}
+
+// digestPeriod returns how long of a period the digest covers
+func digestPeriod(frequency string) time.Duration {
+	if frequency == model.DigestDaily {
+		return 24 * time.Hour
+	}
+
+	return 7 * 24 * time.Hour
+}
+
+// PostDigests posts the digest in every server where one is due
+func PostDigests(bot *eb.Bot) {
+	servers, err := bot.Servers.GetWithDigest()
+
+	if err != nil {
+		fmt.Println("Failed to look up servers for digests", err)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	for _, server := range servers {
+		period := digestPeriod(server.DigestFrequency)
+
+		if server.LastDigestAt == nil {
+			server.LastDigestAt = &now
+			bot.Servers.Save(server)
+			continue
+		} else if now.Sub(*server.LastDigestAt) < period {
+			continue
+		}
+
+		channelID := server.DigestChannelID.String
+
+		if !server.DigestChannelID.Valid {
+			channelID = server.ScoreChannelID.String
+		}
+
+		if channelID != "" {
+			embed, err := getDigestEmbed(bot, server, *server.LastDigestAt, now.Sub(*server.LastDigestAt))
+
+			if err != nil {
+				fmt.Println("Failed to create digest", server.ServerID, err)
+				continue
+			}
+
+			if _, err := bot.Session.ChannelMessageSendEmbed(channelID, embed); err != nil {
+				fmt.Println("Failed to send digest", server.ServerID, err)
+			}
+		}
+
+		server.LastDigestAt = &now
+
+		if err := bot.Servers.Save(server); err != nil {
+			fmt.Println("Failed to save server after digest", server.ServerID, err)
+		}
+	}
+}
+
+// getDigestEmbed creates the digest for the period starting at the given time
+func getDigestEmbed(bot *eb.Bot, server *model.DiscordServer, start time.Time, period time.Duration) (*discordgo.MessageEmbed, error) {
+	end := start.Add(period)
+
+	users, err := bot.Users.GetRegisteredUsers(server.ServerID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	usernames := make(map[uint]string)
+
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Color: embedColor,
+		Title: "Server digest",
+		Description: fmt.Sprintf("What everyone's been up to over the last %s.",
+			util.FormatPeriod(period)),
+	}
+
+	for _, section := range digestSections {
+		if !hasDigestSection(server, section) {
+			continue
+		}
+
+		var value string
+
+		switch section {
+		case digestGains, digestRanks:
+			value, err = getDigestMovers(bot, users, start, section)
+		case digestPlays, digestActive:
+			var scores []*model.Score
+
+			if scores, err = bot.Scores.GetServerScores(server.ServerID, start, end); err == nil {
+				if section == digestPlays {
+					value = formatDigestPlays(bot, scores, usernames)
+				} else {
+					value = formatDigestActive(scores, usernames)
+				}
+			}
+		case digestPBs:
+			var scores []*model.Score
+
+			if scores, err = bot.Scores.GetServerPersonalBests(server.ServerID, start, end); err == nil {
+				value = formatDigestPlays(bot, scores, usernames)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		} else if value == "" {
+			value = "Nothing this time."
+		}
+
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  digestSectionTitles[section],
+			Value: value,
+		})
+	}
+
+	return embed, nil
+}
+
+func hasDigestSection(server *model.DiscordServer, section string) bool {
+	for _, s := range server.DigestSections {
+		if s == section {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getDigestMovers compares each user's cached overall rating and rank with their
+// rating history at the start of the period. Returns the users who gained the most
+// rating (gains) or climbed the most ranks (ranks)
+func getDigestMovers(bot *eb.Bot, users []*model.EtternaUser, start time.Time, section string) (string, error) {
+	type mover struct {
+		username string
+		before   float64
+		after    float64
+	}
+
+	var movers []mover
+
+	for _, u := range users {
+		snapshot, err := bot.Users.GetRatingAt(u.ID, start)
+
+		if err != nil {
+			return "", err
+		} else if snapshot == nil {
+			continue
+		}
+
+		if section == digestGains && u.MSDOverall-snapshot.MSDOverall >= 0.01 {
+			movers = append(movers, mover{u.Username, snapshot.MSDOverall, u.MSDOverall})
+		} else if section == digestRanks && snapshot.RankOverall > 0 && u.RankOverall > 0 && u.RankOverall < snapshot.RankOverall {
+			movers = append(movers, mover{u.Username, float64(snapshot.RankOverall), float64(u.RankOverall)})
+		}
+	}
+
+	// Sort by the biggest change. Both gaining rating and climbing ranks are
+	// positive changes
+	sort.Slice(movers, func(i, j int) bool {
+		if section == digestRanks {
+			return movers[i].before-movers[i].after > movers[j].before-movers[j].after
+		}
+
+		return movers[i].after-movers[i].before > movers[j].after-movers[j].before
+	})
+
+	value := ""
+
+	for i, mv := range movers {
+		if i == digestTopCount {
+			break
+		}
+
+		if section == digestRanks {
+			value += fmt.Sprintf("**%d.** %s #%d → #%d\n", i+1, mv.username, int(mv.before), int(mv.after))
+		} else {
+			value += fmt.Sprintf("**%d.** %s %.2f → %.2f (+%.2f)\n", i+1, mv.username, mv.before, mv.after, mv.after-mv.before)
+		}
+	}
+
+	return value, nil
+}
+
+// formatDigestPlays lists the highest rated scores
+func formatDigestPlays(bot *eb.Bot, scores []*model.Score, usernames map[uint]string) string {
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].MSDOverall > scores[j].MSDOverall
+	})
+
+	value := ""
+
+	for i, s := range scores {
+		if i == digestTopCount {
+			value += fmt.Sprintf("…and %d more\n", len(scores)-digestTopCount)
+			break
+		}
+
+		name := fmt.Sprintf("song #%d", s.SongID)
+
+		if song, err := bot.Songs.Get(s.SongID); err == nil && song != nil {
+			name = song.Name
+		}
+
+		value += fmt.Sprintf("**%d.** %s — %s (%sx) %.2f%% **%.2f**\n",
+			i+1, usernames[s.UserID], name, formatRate(s.Rate), s.Accuracy, s.MSDOverall)
+	}
+
+	return value
+}
+
+// formatDigestActive lists the users with the most plays
+func formatDigestActive(scores []*model.Score, usernames map[uint]string) string {
+	counts := make(map[uint]int)
+	var userIDs []uint
+
+	for _, s := range scores {
+		if counts[s.UserID] == 0 {
+			userIDs = append(userIDs, s.UserID)
+		}
+
+		counts[s.UserID]++
+	}
+
+	sort.Slice(userIDs, func(i, j int) bool {
+		return counts[userIDs[i]] > counts[userIDs[j]]
+	})
+
+	value := ""
+
+	for i, id := range userIDs {
+		if i == digestTopCount {
+			break
+		}
+
+		value += fmt.Sprintf("**%d.** %s — %d plays\n", i+1, usernames[id], counts[id])
+	}
+
+	return value
+}
@@ -0,0 +1,362 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/etterna"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	maxOpenGoals     = 10 // Max number of goals a user can be working on
+	goalHistoryCount = 15 // Number of completed goals to show in the history
+)
+
+var (
+	reScoreGoal = regexp.MustCompile(`(?i)^(\S+)\s+on\s+(.+?)(?:\s+at\s+(\d*\.?\d+x?))?$`)
+)
+
+// CmdGoal adds, removes and lists the goals of the registered user. Goals are
+// checked against each new play by the recent plays tracker
+func CmdGoal(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	user, err := bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if user == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You are not registered with an Etterna user. "+
+			"Please register using the `setuser` command first.")
+		return
+	}
+
+	if len(args) == 1 || strings.ToLower(args[1]) == "list" {
+		listGoals(bot, m, user)
+		return
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "add":
+		addGoal(bot, m, user, args[2:])
+	case "remove":
+		removeGoal(bot, m, user, args[2:])
+	case "history":
+		listCompletedGoals(bot, m, user)
+	default:
+		bot.Session.ChannelMessageSend(m.ChannelID, "Usage: goal [list | add <goal> | remove <number> | history]")
+	}
+}
+
+func addGoal(bot *eb.Bot, m *discordgo.MessageCreate, user *model.EtternaUser, args []string) {
+	goal, songQuery, err := parseGoal(args)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	goals, err := bot.Goals.GetOpen(user.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if len(goals) >= maxOpenGoals {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("You can only have %d goals at a time. Remove one first.", maxOpenGoals))
+		return
+	}
+
+	if songQuery != "" {
+		song, err := findSong(bot, songQuery)
+
+		if err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		} else if song == nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, "Could not find a song with that name. Use the `song` command to look up its ID.")
+			return
+		}
+
+		goal.SongID.Int64 = int64(song.EtternaID)
+		goal.SongID.Valid = true
+	} else if isGoalComplete(goal, user.MSD(), user.Rank(), nil) {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You've already reached that goal!")
+		return
+	}
+
+	goal.UserID = user.ID
+
+	if err := bot.Goals.Save(goal); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, "Added goal: "+describeGoal(bot, goal))
+}
+
+func removeGoal(bot *eb.Bot, m *discordgo.MessageCreate, user *model.EtternaUser, args []string) {
+	if len(args) != 1 {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Usage: goal remove <number>")
+		return
+	}
+
+	n, err := strconv.Atoi(args[0])
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Usage: goal remove <number>")
+		return
+	}
+
+	goals, err := bot.Goals.GetOpen(user.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if n < 1 || n > len(goals) {
+		bot.Session.ChannelMessageSend(m.ChannelID, "There's no goal with that number. Use `goal list` to see your goals.")
+		return
+	}
+
+	if err := bot.Goals.Delete(goals[n-1].ID); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, "Removed goal: "+describeGoal(bot, goals[n-1]))
+}
+
+func listGoals(bot *eb.Bot, m *discordgo.MessageCreate, user *model.EtternaUser) {
+	goals, err := bot.Goals.GetOpen(user.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	description := "You don't have any goals yet. Add one with `goal add`, e.g. `goal add overall 25`, " +
+		"`goal add AAA on <song> at 1.2x` or `goal add top 500 in stream`."
+
+	if len(goals) > 0 {
+		description = ""
+	}
+
+	for i, g := range goals {
+		description += fmt.Sprintf("**%d.** %s\n", i+1, describeGoal(bot, g))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Color:       embedColor,
+		Description: description,
+		Author: &discordgo.MessageEmbedAuthor{
+			IconURL: bot.API.BaseURL() + "/avatars/" + user.Avatar,
+			Name:    user.Username + "'s goals",
+		},
+	}
+
+	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+}
+
+func listCompletedGoals(bot *eb.Bot, m *discordgo.MessageCreate, user *model.EtternaUser) {
+	goals, err := bot.Goals.GetCompleted(user.ID, goalHistoryCount)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	description := "You haven't completed any goals yet."
+
+	if len(goals) > 0 {
+		description = ""
+	}
+
+	for _, g := range goals {
+		description += fmt.Sprintf("✓ %s — %s\n", describeGoal(bot, g), g.CompletedAt.Format("Jan 2, 2006"))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Color:       embedColor,
+		Description: description,
+		Author: &discordgo.MessageEmbedAuthor{
+			IconURL: bot.API.BaseURL() + "/avatars/" + user.Avatar,
+			Name:    user.Username + "'s completed goals",
+		},
+	}
+
+	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+}
+
+// parseGoal parses one of these goals:
+//
+//	<skillset> <rating>                        e.g. overall 25.00
+//	top <rank> [in <skillset>]                 e.g. top 500 in stream
+//	<grade|accuracy%> on <song> [at <rate>]    e.g. AAA on Ghost Rule at 1.2x
+//
+// For score goals, the song name/ID is returned so it can be looked up
+func parseGoal(args []string) (*model.Goal, string, error) {
+	usage := errors.New("Usage: goal add <skillset> <rating> | top <rank> [in <skillset>] | <grade or acc%> on <song> [at <rate>]")
+
+	if len(args) == 0 {
+		return nil, "", usage
+	}
+
+	// Rank goal
+	if strings.ToLower(args[0]) == "top" {
+		if len(args) < 2 {
+			return nil, "", usage
+		}
+
+		rank, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+
+		if err != nil || rank < 1 {
+			return nil, "", errors.New("Rank must be a number, e.g. top 500")
+		}
+
+		goal := &model.Goal{Kind: model.GoalRank, Skillset: etterna.SkillsetOverall, Target: float64(rank)}
+		rest := args[2:]
+
+		if len(rest) > 0 && strings.ToLower(rest[0]) == "in" {
+			rest = rest[1:]
+		}
+
+		if len(rest) == 1 {
+			ss, ok := etterna.ParseSkillset(rest[0])
+
+			if !ok {
+				return nil, "", usage
+			}
+
+			goal.Skillset = ss
+		} else if len(rest) > 1 {
+			return nil, "", usage
+		}
+
+		return goal, "", nil
+	}
+
+	// Rating goal
+	if ss, ok := etterna.ParseSkillset(args[0]); ok && len(args) == 2 {
+		rating, err := strconv.ParseFloat(args[1], 64)
+
+		if err != nil || rating <= 0 || rating >= 100 {
+			return nil, "", errors.New("Rating must be a number, e.g. overall 25.00")
+		}
+
+		return &model.Goal{Kind: model.GoalRating, Skillset: ss, Target: rating}, "", nil
+	}
+
+	// Score goal
+	match := reScoreGoal.FindStringSubmatch(strings.Join(args, " "))
+
+	if match == nil {
+		return nil, "", usage
+	}
+
+	goal := &model.Goal{Kind: model.GoalScore, Rate: 1.0}
+
+	if grade, ok := etterna.ParseGrade(match[1]); ok {
+		// Any play is at least a D, so it wouldn't be much of a goal
+		if grade.MinAccuracy() <= 0 {
+			return nil, "", errors.New("The grade must be C or better.")
+		}
+
+		goal.Target = grade.MinAccuracy()
+	} else if acc, err := strconv.ParseFloat(strings.TrimSuffix(match[1], "%"), 64); err == nil && acc > 0 && acc <= 100 {
+		goal.Target = acc
+	} else {
+		return nil, "", errors.New("The score must be a grade (e.g. AAA) or an accuracy (e.g. 98.5%)")
+	}
+
+	if match[3] != "" {
+		rate, err := parseRate(match[3])
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		goal.Rate = rate
+	}
+
+	return goal, strings.TrimSpace(match[2]), nil
+}
+
+// isGoalComplete checks if the goal has been reached with the given ratings and ranks.
+// Score goals are only checked against the score, which can be nil
+func isGoalComplete(goal *model.Goal, msd etterna.MSD, rank etterna.Rank, score *etterna.Score) bool {
+	switch goal.Kind {
+	case model.GoalRating:
+		return msd.Get(goal.Skillset) >= goal.Target
+	case model.GoalRank:
+		r := rank.Get(goal.Skillset)
+		return r > 0 && float64(r) <= goal.Target
+	case model.GoalScore:
+		return score != nil &&
+			goal.SongID.Valid &&
+			score.Song.ID == int(goal.SongID.Int64) &&
+			score.Rate >= goal.Rate &&
+			score.Accuracy >= goal.Target
+	}
+
+	return false
+}
+
+// checkGoals marks any of the user's open goals that were reached with the latest
+// play as complete. Returns a summary of the completed goals
+func checkGoals(bot *eb.Bot, user *model.EtternaUser, score *etterna.Score) string {
+	goals, err := bot.Goals.GetOpen(user.ID)
+
+	if err != nil {
+		fmt.Println("Failed to look up goals", user.Username, err)
+		return ""
+	}
+
+	completed := ""
+	now := time.Now().UTC()
+
+	for _, g := range goals {
+		if !isGoalComplete(g, user.MSD(), user.Rank(), score) {
+			continue
+		}
+
+		g.CompletedAt = &now
+
+		if err := bot.Goals.Save(g); err != nil {
+			fmt.Println("Failed to complete goal", g.ID, err)
+			continue
+		}
+
+		completed += fmt.Sprintf("🎯 **Goal complete:** %s\n", describeGoal(bot, g))
+	}
+
+	return strings.TrimSuffix(completed, "\n")
+}
+
+// describeGoal returns a short description of the goal, e.g. "Overall 25.00"
+func describeGoal(bot *eb.Bot, goal *model.Goal) string {
+	switch goal.Kind {
+	case model.GoalRating:
+		return fmt.Sprintf("%s %.2f", goal.Skillset, goal.Target)
+	case model.GoalRank:
+		return fmt.Sprintf("Top %d in %s", int(goal.Target), goal.Skillset)
+	}
+
+	score := fmt.Sprintf("%.2f%%", goal.Target)
+
+	if grade := etterna.GetGrade(goal.Target); grade.MinAccuracy() == goal.Target {
+		score = string(grade)
+	}
+
+	name := fmt.Sprintf("song #%d", goal.SongID.Int64)
+
+	if song, err := bot.Songs.Get(int(goal.SongID.Int64)); err == nil && song != nil {
+		name = song.Name
+	}
+
+	return fmt.Sprintf("%s on %s at %sx", score, name, formatRate(goal.Rate))
+}
@@ -0,0 +1,264 @@
+package bot
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"net/http"
+	"strings"
+	"time"
+
+	// Avatars can be any of these formats
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/chart"
+	"github.com/Kangaroux/etternabot/etterna"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/Kangaroux/etternabot/util"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	defaultGraphPeriod = 90 * 24 * time.Hour // How far back the graph command looks by default
+	avatarTimeout      = 10 * time.Second    // How long to wait for an avatar to download
+)
+
+var avatarClient = &http.Client{Timeout: avatarTimeout}
+
+// CmdGraph draws a graph of a user's ratings over time. If a skillset isn't given,
+// every skillset is drawn. A second user can be overlaid with "vs <username>" to
+// compare the two
+func CmdGraph(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	var err error
+	var user, other *model.EtternaUser
+	var username, otherUsername string
+
+	period := defaultGraphPeriod
+	skillset := etterna.SkillsetOverall
+	hasSkillset := false
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "" {
+			continue
+		} else if strings.ToLower(arg) == "vs" && i+1 < len(args) {
+			otherUsername = args[i+1]
+			i++
+		} else if ss, ok := etterna.ParseSkillset(arg); ok {
+			skillset = ss
+			hasSkillset = true
+		} else if p, ok := util.ParsePeriod(arg); ok {
+			period = p
+		} else if username == "" {
+			username = arg
+		} else {
+			bot.Session.ChannelMessageSend(m.ChannelID, "Usage: graph [username] [skillset] [period] [vs <username>]")
+			return
+		}
+	}
+
+	if username == "" {
+		user, err = bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID)
+	} else {
+		user, err = getUserOrCreate(bot, username, false)
+	}
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if user == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You are not registered with an Etterna user. "+
+			"Please register using the `setuser` command, or specify a user: graph <username>")
+		return
+	}
+
+	if otherUsername != "" {
+		if other, err = getUserOrCreate(bot, otherUsername, false); err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		}
+	}
+
+	bot.Session.ChannelTyping(m.ChannelID)
+
+	end := time.Now().UTC()
+	start := end.Add(-period)
+	var series []chart.Series
+	var title string
+
+	if other != nil {
+		title = fmt.Sprintf("%s: %s vs. %s, last %s", skillset, user.Username, other.Username, util.FormatPeriod(period))
+
+		for i, u := range []*model.EtternaUser{user, other} {
+			points, err := getRatingPoints(bot, u, start, end)
+
+			if err != nil {
+				bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+				return
+			}
+
+			series = append(series, chart.Series{
+				Name:   u.Username,
+				Color:  chart.Palette[i+1],
+				Points: points[skillset],
+			})
+		}
+	} else {
+		points, err := getRatingPoints(bot, user, start, end)
+
+		if err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		}
+
+		title = fmt.Sprintf("%s, last %s", user.Username, util.FormatPeriod(period))
+
+		for _, ss := range etterna.Skillsets {
+			if hasSkillset && ss != skillset {
+				continue
+			}
+
+			series = append(series, chart.Series{
+				Name:   ss.String(),
+				Color:  skillsetColor(ss),
+				Points: points[ss],
+			})
+		}
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Color: embedColor,
+		Author: &discordgo.MessageEmbedAuthor{
+			IconURL: "https://i.imgur.com/HwIkGCk.png",
+			Name:    "Rating history",
+		},
+	}
+
+	if err := sendEmbedWithImage(bot, m.ChannelID, embed, "graph.png", chart.LineGraph(title, series)); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+	}
+}
+
+// getSkillsetRadarChart draws a radar chart of the skillsets of each user
+func getSkillsetRadarChart(bot *eb.Bot, users ...*model.EtternaUser) image.Image {
+	var axes []string
+	var series []chart.RadarSeries
+
+	for _, ss := range etterna.Skillsets {
+		axes = append(axes, ss.String())
+	}
+
+	for i, u := range users {
+		var values []float64
+
+		for _, ss := range etterna.Skillsets {
+			values = append(values, u.MSD().Get(ss))
+		}
+
+		series = append(series, chart.RadarSeries{
+			Name:   u.Username,
+			Color:  chart.Palette[i+1],
+			Values: values,
+			Avatar: getAvatarImage(bot, u),
+		})
+	}
+
+	return chart.RadarChart(axes, series)
+}
+
+// getAvatarImage downloads the user's avatar. Returns nil if the avatar couldn't be
+// downloaded, since a chart can still be drawn without it
+func getAvatarImage(bot *eb.Bot, user *model.EtternaUser) image.Image {
+	resp, err := avatarClient.Get(bot.API.BaseURL() + "/avatars/" + user.Avatar)
+
+	if err != nil {
+		fmt.Println("Failed to download avatar", user.Username, err)
+		return nil
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Println("Failed to download avatar", user.Username, resp.StatusCode)
+		return nil
+	}
+
+	img, _, err := image.Decode(resp.Body)
+
+	if err != nil {
+		fmt.Println("Failed to decode avatar", user.Username, err)
+		return nil
+	}
+
+	return img
+}
+
+// sendEmbedWithImage sends the embed with the image attached as a PNG and displayed
+// inside the embed
+func sendEmbedWithImage(bot *eb.Bot, channelID string, embed *discordgo.MessageEmbed, filename string, img image.Image) error {
+	buf, err := chart.EncodePNG(img)
+
+	if err != nil {
+		return err
+	}
+
+	embed.Image = &discordgo.MessageEmbedImage{
+		URL: "attachment://" + filename,
+	}
+
+	_, err = bot.Session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embed: embed,
+		Files: []*discordgo.File{
+			&discordgo.File{
+				Name:        filename,
+				ContentType: "image/png",
+				Reader:      buf,
+			},
+		},
+	})
+
+	return err
+}
+
+// getRatingPoints returns the user's rating in each skillset over the given time range
+func getRatingPoints(bot *eb.Bot, user *model.EtternaUser, start, end time.Time) (map[etterna.Skillset][]chart.Point, error) {
+	history, err := bot.Users.GetRatingHistory(user.ID, start, end)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Start the graph with whatever the rating was at the start of the range
+	before, err := bot.Users.GetRatingAt(user.ID, start)
+
+	if err != nil {
+		return nil, err
+	} else if before != nil {
+		before.CreatedAt = start
+		history = append([]*model.RatingSnapshot{before}, history...)
+	}
+
+	points := make(map[etterna.Skillset][]chart.Point)
+
+	for _, ss := range etterna.Skillsets {
+		for _, h := range history {
+			points[ss] = append(points[ss], chart.Point{Time: h.CreatedAt, Value: h.MSD().Get(ss)})
+		}
+
+		// The rating stays the same until the next snapshot so extend the line to the end
+		if len(history) > 0 {
+			points[ss] = append(points[ss], chart.Point{Time: end, Value: history[len(history)-1].MSD().Get(ss)})
+		}
+	}
+
+	return points, nil
+}
+
+// skillsetColor returns the color used for drawing a skillset in a chart
+func skillsetColor(ss etterna.Skillset) color.Color {
+	return chart.Palette[int(ss)%len(chart.Palette)]
+}
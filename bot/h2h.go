@@ -0,0 +1,259 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/etterna"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	h2hMargins = 3 // Number of the biggest wins/losses to show for each player
+)
+
+// headToHead is the result of comparing two players' scores on the songs they've
+// both played
+type headToHead struct {
+	sharedSongs int       // Songs both players have a score on, at any rate
+	wins        []h2hPlay // Plays where the first player had the better accuracy
+	losses      []h2hPlay // Plays where the second player had the better accuracy
+	ties        int
+
+	// The average of the first player's rating minus the second player's rating in
+	// each skillset, across every play at a matching rate
+	skillsetDiff map[etterna.Skillset]float64
+}
+
+// songRate identifies a song played at a specific rate
+type songRate struct {
+	songID int
+	rate   float64
+}
+
+// h2hPlay is a pair of scores on the same song at the same rate
+type h2hPlay struct {
+	score1 *model.Score
+	score2 *model.Score
+}
+
+// margin returns how much more accurate the first player's score was
+func (p h2hPlay) margin() float64 {
+	return p.score1.Accuracy - p.score2.Accuracy
+}
+
+// CmdHeadToHead compares the scores of two players on the songs they've both played
+func CmdHeadToHead(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	var err error
+	var user1, user2 *model.EtternaUser
+
+	if len(args) == 1 || len(args) > 3 {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Usage: h2h <username> [username]")
+		return
+	}
+
+	if len(args) == 2 {
+		user1, err = bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID)
+
+		if err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		} else if user1 == nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, "You are not registered with an Etterna user. "+
+				"Please register using the `setuser` command, or specify two users: h2h <username> <username>")
+			return
+		}
+
+		user2, err = getUserOrCreate(bot, args[1], false)
+	} else {
+		if user1, err = getUserOrCreate(bot, args[1], false); err == nil {
+			user2, err = getUserOrCreate(bot, args[2], false)
+		}
+	}
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if user1.ID == user2.ID {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Pick two different players.")
+		return
+	}
+
+	bot.Session.ChannelTyping(m.ChannelID)
+
+	var syncing []string
+
+	for _, u := range []*model.EtternaUser{user1, user2} {
+		ready, err := prepareScores(bot, u)
+
+		if err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Failed to look up the scores of %s (%s)", u.Username, err.Error()))
+			return
+		} else if !ready {
+			syncing = append(syncing, u.Username)
+		}
+	}
+
+	if len(syncing) > 0 {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Looking up every score of %s for the first time. "+
+			"This can take a few minutes, try again later.", strings.Join(syncing, " and ")))
+		return
+	}
+
+	scores1, err := bot.Scores.GetBestPerSongRate(user1.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	scores2, err := bot.Scores.GetBestPerSongRate(user2.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	h2h := compareScores(scores1, scores2)
+	played := len(h2h.wins) + len(h2h.losses) + h2h.ties
+
+	embed := &discordgo.MessageEmbed{
+		Color: embedColor,
+		Author: &discordgo.MessageEmbedAuthor{
+			IconURL: bot.API.BaseURL() + "/avatars/" + user1.Avatar,
+			Name:    user1.Username + " vs. " + user2.Username + " (head to head)",
+		},
+		Thumbnail: &discordgo.MessageEmbedThumbnail{
+			URL: bot.API.BaseURL() + "/avatars/" + user2.Avatar,
+		},
+	}
+
+	if played == 0 {
+		embed.Description = fmt.Sprintf("%s and %s have %d songs in common, but no plays at the same rate.",
+			user1.Username, user2.Username, h2h.sharedSongs)
+		bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+		return
+	}
+
+	embed.Description = fmt.Sprintf("**%d** songs in common, **%d** plays at the same rate\n\n"+
+		"**%s:** %d wins\n**%s:** %d wins\n**Ties:** %d",
+		h2h.sharedSongs, played, user1.Username, len(h2h.wins), user2.Username, len(h2h.losses), h2h.ties)
+
+	embed.Fields = []*discordgo.MessageEmbedField{
+		{
+			Name:  "Biggest wins by " + user1.Username,
+			Value: formatH2HPlays(bot, h2h.wins, 1),
+		},
+		{
+			Name:  "Biggest wins by " + user2.Username,
+			Value: formatH2HPlays(bot, h2h.losses, -1),
+		},
+		{
+			Name:  fmt.Sprintf("Average rating difference (%s − %s)", user1.Username, user2.Username),
+			Value: formatSkillsetDiff(h2h.skillsetDiff),
+		},
+	}
+
+	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+}
+
+// compareScores pairs up the scores of two players that were played on the same song
+// at the same rate. The scores should be each player's best score per song and rate
+func compareScores(scores1, scores2 []*model.Score) headToHead {
+	h2h := headToHead{skillsetDiff: make(map[etterna.Skillset]float64)}
+
+	bySongRate := make(map[songRate]*model.Score)
+	songs := make(map[int]bool)
+	sharedSongs := make(map[int]bool)
+
+	for _, s := range scores2 {
+		bySongRate[songRate{s.SongID, s.Rate}] = s
+		songs[s.SongID] = true
+	}
+
+	for _, s1 := range scores1 {
+		if songs[s1.SongID] {
+			sharedSongs[s1.SongID] = true
+		}
+
+		s2, exists := bySongRate[songRate{s1.SongID, s1.Rate}]
+
+		if !exists {
+			continue
+		}
+
+		play := h2hPlay{score1: s1, score2: s2}
+
+		if play.margin() > 0 {
+			h2h.wins = append(h2h.wins, play)
+		} else if play.margin() < 0 {
+			h2h.losses = append(h2h.losses, play)
+		} else {
+			h2h.ties++
+		}
+
+		for _, ss := range etterna.Skillsets {
+			h2h.skillsetDiff[ss] += s1.MSD().Get(ss) - s2.MSD().Get(ss)
+		}
+	}
+
+	h2h.sharedSongs = len(sharedSongs)
+
+	sort.SliceStable(h2h.wins, func(i, j int) bool {
+		return h2h.wins[i].margin() > h2h.wins[j].margin()
+	})
+
+	sort.SliceStable(h2h.losses, func(i, j int) bool {
+		return h2h.losses[i].margin() < h2h.losses[j].margin()
+	})
+
+	if played := len(h2h.wins) + len(h2h.losses) + h2h.ties; played > 0 {
+		for ss := range h2h.skillsetDiff {
+			h2h.skillsetDiff[ss] /= float64(played)
+		}
+	}
+
+	return h2h
+}
+
+// formatH2HPlays lists the first few plays with the winner's margin. sign flips the
+// margin so it's positive for the winner
+func formatH2HPlays(bot *eb.Bot, plays []h2hPlay, sign float64) string {
+	if len(plays) == 0 {
+		return "None"
+	}
+
+	var s string
+
+	for i, p := range plays {
+		if i == h2hMargins {
+			break
+		}
+
+		name := fmt.Sprintf("Song #%d", p.score1.SongID)
+
+		if song, err := bot.Songs.Get(p.score1.SongID); err == nil && song != nil {
+			name = song.Name
+		}
+
+		s += fmt.Sprintf("[%s (%sx)](%s/song/view/%d) — %.2f%% vs. %.2f%% (+%.2f%%)\n",
+			name, formatRate(p.score1.Rate), bot.API.BaseURL(), p.score1.SongID,
+			p.score1.Accuracy, p.score2.Accuracy, p.margin()*sign)
+	}
+
+	return s
+}
+
+// formatSkillsetDiff formats the rating difference in each skillset
+func formatSkillsetDiff(diff map[etterna.Skillset]float64) string {
+	s := "```"
+
+	for _, ss := range etterna.Skillsets {
+		s += fmt.Sprintf("%10s:  %+.2f\n", ss, diff[ss])
+	}
+
+	return s + "```"
+}
@@ -0,0 +1,321 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/etterna"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/Kangaroux/etternabot/util"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	leaderboardStaleAfter    = 6 * time.Hour // How old a cached user can be before it's refreshed
+	leaderboardRefreshWorker = 4             // Number of users to refresh at once
+	songLeaderboardWorker    = 4             // Number of users to look up scores for at once
+)
+
+// Servers which are currently having their leaderboard refreshed
+var refreshingServers = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: make(map[string]bool)}
+
+// The latest ratings and ranks of users on the server leaderboard, keyed by username.
+// These are kept apart from the saved users so the recent plays tracker can still
+// announce what changed since the user was last saved
+var leaderboardRatings = struct {
+	sync.Mutex
+	m map[string]leaderboardRating
+}{m: make(map[string]leaderboardRating)}
+
+type leaderboardRating struct {
+	msd       etterna.MSD
+	rank      etterna.Rank
+	updatedAt time.Time
+}
+
+// CmdServerLeaderboard lists the players registered in the server ordered by their
+// rating in a skillset (overall by default)
+func CmdServerLeaderboard(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	skillset := etterna.SkillsetOverall
+
+	if len(args) > 1 {
+		ss, ok := etterna.ParseSkillset(args[1])
+
+		if !ok {
+			bot.Session.ChannelMessageSend(m.ChannelID, "Usage: serverlb [skillset]")
+			return
+		}
+
+		skillset = ss
+	}
+
+	users, err := bot.Users.GetRegisteredUsers(m.GuildID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if len(users) == 0 {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Nobody in this server is registered yet. Use the `setuser` command to register.")
+		return
+	}
+
+	ratings := getLeaderboardRatings(users)
+
+	sort.SliceStable(users, func(i, j int) bool {
+		return ratings[users[i].ID].msd.Get(skillset) > ratings[users[j].ID].msd.Get(skillset)
+	})
+
+	var lines []string
+
+	for i, u := range users {
+		line := fmt.Sprintf("**%d.** %s — %.2f", i+1, u.Username, ratings[u.ID].msd.Get(skillset))
+
+		if rank := ratings[u.ID].rank.Get(skillset); rank > 0 {
+			line += fmt.Sprintf(" (#%d)", rank)
+		}
+
+		lines = append(lines, line)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Color: embedColor,
+		Author: &discordgo.MessageEmbedAuthor{
+			IconURL: "https://i.imgur.com/HwIkGCk.png",
+			Name:    fmt.Sprintf("Server leaderboard: %s", skillset),
+		},
+	}
+
+	if err := sendPaginatedEmbed(bot, m.ChannelID, embed, lines, defaultPageSize); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	go refreshStaleUsers(bot, m.GuildID, users, ratings)
+}
+
+// CmdSongLeaderboard ranks the best scores of everyone registered in the server on
+// a song. The song defaults to the score being replied to, or the current song in
+// the channel. Without a rate, scores are ranked by their rating, otherwise they are
+// ranked by accuracy
+func CmdSongLeaderboard(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate, args []string) {
+	var err error
+	var rate float64
+	var song *model.Song
+
+	args = args[1:]
+
+	// Rates are always written with a decimal point or an x (1.0, 1x) so they can't be
+	// confused with a song ID. Anything that isn't a valid rate is part of the song name
+	if len(args) > 0 && (strings.Contains(args[0], ".") || strings.HasSuffix(strings.ToLower(args[0]), "x")) {
+		if r, err := parseRate(args[0]); err == nil {
+			rate = r
+			args = args[1:]
+		}
+	}
+
+	bot.Session.ChannelTyping(m.ChannelID)
+
+	songID, _, hasSong, err := getTargetSong(bot, server, m)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	if query := strings.TrimSpace(strings.Join(args, " ")); query != "" {
+		song, err = findSong(bot, query)
+	} else if hasSong {
+		song, err = getSongOrCreate(bot, songID)
+	} else {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Usage: songlb [rate] [song name or ID]")
+		return
+	}
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if song == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Could not find a song with that name.")
+		return
+	}
+
+	users, err := bot.Users.GetRegisteredUsers(m.GuildID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	plays := getBestPlaysOnSong(bot, users, song, rate)
+
+	sort.SliceStable(plays, func(i, j int) bool {
+		if rate == 0 {
+			return plays[i].Score.Nerfed > plays[j].Score.Nerfed
+		}
+
+		return plays[i].Score.Accuracy > plays[j].Score.Accuracy
+	})
+
+	var lines []string
+
+	for i, p := range plays {
+		lines = append(lines, fmt.Sprintf("**%d.** %s — %.2f%% @ %sx (%.2f)",
+			i+1, p.User.Username, p.Score.Accuracy, formatRate(p.Score.Rate), p.Score.Overall))
+	}
+
+	title := "Server leaderboard: " + song.Name
+
+	if rate > 0 {
+		title += fmt.Sprintf(" (%sx)", formatRate(rate))
+	}
+
+	if len(lines) == 0 {
+		lines = []string{"Nobody in this server has played this song yet."}
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Color: embedColor,
+		URL:   fmt.Sprintf("%s/song/view/%d", bot.API.BaseURL(), song.EtternaID),
+		Title: title,
+		Thumbnail: &discordgo.MessageEmbedThumbnail{
+			URL: bot.API.BaseURL() + "/song_images/bg/" + song.BackgroundURL,
+		},
+	}
+
+	if err := sendPaginatedEmbed(bot, m.ChannelID, embed, lines, defaultPageSize); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+	}
+}
+
+// getBestPlaysOnSong looks up the best score of each user on a song. Users without
+// a score on the song are left out
+func getBestPlaysOnSong(bot *eb.Bot, users []*model.EtternaUser, song *model.Song, rate float64) []eb.Play {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var plays []eb.Play
+
+	queue := make(chan *model.EtternaUser)
+
+	for i := 0; i < songLeaderboardWorker; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for u := range queue {
+				score, err := getBestScoreOnSong(bot, u, song, rate)
+
+				if err != nil {
+					fmt.Println("Failed to get best score", u.Username, song.EtternaID, err)
+					continue
+				} else if score == nil {
+					continue
+				}
+
+				mu.Lock()
+				plays = append(plays, eb.Play{Score: *score, User: *u})
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, u := range users {
+		queue <- u
+	}
+
+	close(queue)
+	wg.Wait()
+
+	return plays
+}
+
+// getLeaderboardRatings returns the ratings and ranks to show for each user keyed by
+// their ID, using the leaderboard's own copy if it's newer than the saved user
+func getLeaderboardRatings(users []*model.EtternaUser) map[uint]leaderboardRating {
+	ratings := make(map[uint]leaderboardRating)
+
+	leaderboardRatings.Lock()
+	defer leaderboardRatings.Unlock()
+
+	for _, u := range users {
+		ratings[u.ID] = leaderboardRating{u.MSD(), u.Rank(), u.UpdatedAt}
+
+		if r, ok := leaderboardRatings.m[strings.ToLower(u.Username)]; ok && r.updatedAt.After(u.UpdatedAt) {
+			ratings[u.ID] = r
+		}
+	}
+
+	return ratings
+}
+
+// refreshStaleUsers looks up the latest ratings and ranks of any users who haven't
+// been updated in a while, so the next leaderboard isn't out of date. The saved users
+// are left alone. Only one refresh runs per server at a time
+func refreshStaleUsers(bot *eb.Bot, serverID string, users []*model.EtternaUser, ratings map[uint]leaderboardRating) {
+	refreshingServers.Lock()
+
+	if refreshingServers.m[serverID] {
+		refreshingServers.Unlock()
+		return
+	}
+
+	refreshingServers.m[serverID] = true
+	refreshingServers.Unlock()
+
+	defer func() {
+		refreshingServers.Lock()
+		delete(refreshingServers.m, serverID)
+		refreshingServers.Unlock()
+	}()
+
+	var wg sync.WaitGroup
+	queue := make(chan string)
+
+	for i := 0; i < leaderboardRefreshWorker; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for username := range queue {
+				latest, err := bot.API.GetByUsername(username)
+
+				if err != nil {
+					fmt.Println("Failed to refresh user", username, err)
+					continue
+				}
+
+				msd := etterna.MSD{
+					Overall:    util.RoundToPrecision(latest.Overall, 2),
+					Stream:     util.RoundToPrecision(latest.Stream, 2),
+					Jumpstream: util.RoundToPrecision(latest.Jumpstream, 2),
+					Handstream: util.RoundToPrecision(latest.Handstream, 2),
+					Stamina:    util.RoundToPrecision(latest.Stamina, 2),
+					JackSpeed:  util.RoundToPrecision(latest.JackSpeed, 2),
+					Chordjack:  util.RoundToPrecision(latest.Chordjack, 2),
+					Technical:  util.RoundToPrecision(latest.Technical, 2),
+				}
+
+				leaderboardRatings.Lock()
+				leaderboardRatings.m[strings.ToLower(username)] = leaderboardRating{msd, latest.Rank, time.Now().UTC()}
+				leaderboardRatings.Unlock()
+			}
+		}()
+	}
+
+	for _, u := range users {
+		if time.Since(ratings[u.ID].updatedAt) >= leaderboardStaleAfter {
+			queue <- u.Username
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+}
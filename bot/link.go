@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+// registerHere registers the user as the etterna user in this server only
+func registerHere(bot *eb.Bot, m *discordgo.MessageCreate, username string) {
+	ok, err := bot.Users.Register(username, m.GuildID, m.Author.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	if !ok {
+		bot.Session.ChannelMessageSend(m.ChannelID,
+			"You are currently registered as another user in this server. Use the 'unset' command "+
+				"first and try again.")
+	} else {
+		bot.Session.ChannelMessageSend(m.ChannelID,
+			fmt.Sprintf("Success! You are now registered as '%s' in this server.", username))
+	}
+}
+
+// linkUser links the user to the etterna user in every server they share with the bot
+func linkUser(bot *eb.Bot, m *discordgo.MessageCreate, username string, verified bool) {
+	link, err := bot.Users.GetLink(m.Author.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if link == nil {
+		link = &model.Link{DiscordUserID: m.Author.ID}
+	}
+
+	link.Username = username
+	link.Verified = verified
+
+	ok, err := bot.Users.Link(link)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if !ok {
+		bot.Session.ChannelMessageSend(m.ChannelID,
+			fmt.Sprintf("Another user is already linked to '%s'. Use `setuser %s -here` to register in this server only.", username, username))
+		return
+	}
+
+	if err := bot.Users.AddMembers(m.GuildID, []string{m.Author.ID}); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	addLinkedMemberships(bot, m.Author.ID)
+
+	// The user may have opted out of their old link in this server
+	user, err := bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if user == nil {
+		ok, err := bot.Users.Register(username, m.GuildID, m.Author.ID)
+
+		if err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		} else if !ok {
+			bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("You are now linked to '%s' in every server, "+
+				"but another user is registered as '%s' in this one. Ask an admin to remove their registration.", username, username))
+			return
+		}
+	} else if user.Username != username {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Success! You are now registered as '%s' in every "+
+			"server, except this one where you're registered as '%s'.", username, user.Username))
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID,
+		fmt.Sprintf("Success! You are now registered as '%s' in every server you share with me.", username))
+}
+
+// hasUnverifiedLink returns whether the discord user is linked to the etterna user
+// but never verified it. Someone who verifies they own the etterna user can take it
+// over from them, including their registrations as it in each server
+func hasUnverifiedLink(bot *eb.Bot, discordID, username string) (bool, error) {
+	link, err := bot.Users.GetLink(discordID)
+
+	if err != nil || link == nil {
+		return false, err
+	}
+
+	return !link.Verified && strings.EqualFold(link.Username, username), nil
+}
+
+// addLinkedMemberships records the servers that the user is in, so their global link
+// applies in each of them
+func addLinkedMemberships(bot *eb.Bot, discordID string) {
+	var serverIDs []string
+
+	bot.Session.State.RLock()
+	for _, g := range bot.Session.State.Guilds {
+		serverIDs = append(serverIDs, g.ID)
+	}
+	bot.Session.State.RUnlock()
+
+	for _, id := range serverIDs {
+		// The member cache may not have everyone, so fall back to asking discord
+		if _, err := bot.Session.State.Member(id, discordID); err != nil {
+			if _, err := bot.Session.GuildMember(id, discordID); err != nil {
+				continue
+			}
+		}
+
+		if err := bot.Users.AddMembers(id, []string{discordID}); err != nil {
+			fmt.Println("Failed to add member", id, discordID, err)
+		}
+	}
+}
+
+// addGuildMembers records that the members are in the server so the global links of
+// any linked members apply there
+func addGuildMembers(bot *eb.Bot, serverID string, members []*discordgo.Member) {
+	if len(members) == 0 {
+		return
+	}
+
+	var discordIDs []string
+
+	for _, member := range members {
+		discordIDs = append(discordIDs, member.User.ID)
+	}
+
+	if err := bot.Users.AddMembers(serverID, discordIDs); err != nil {
+		fmt.Println("Failed to add members", serverID, err)
+	}
+}
+
+func guildMemberAdd(bot *eb.Bot, m *discordgo.GuildMemberAdd) {
+	addGuildMembers(bot, m.GuildID, []*discordgo.Member{m.Member})
+}
+
+// guildMemberRemove removes the member's registration and pending verification when
+// they leave the server
+func guildMemberRemove(bot *eb.Bot, m *discordgo.GuildMemberRemove) {
+	registered, err := bot.Users.RemoveMember(m.GuildID, m.User.ID)
+
+	if err != nil {
+		fmt.Println("Failed to remove member", m.GuildID, m.User.ID, err)
+		return
+	} else if registered {
+		fmt.Println("Removed registration for member who left", m.GuildID, m.User.ID)
+	}
+
+	if err := bot.Verifications.Delete(m.GuildID, m.User.ID); err != nil {
+		fmt.Println("Failed to remove verification", m.GuildID, m.User.ID, err)
+	}
+}
@@ -0,0 +1,753 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/etterna"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	matchCheckInterval = time.Minute // How often to check for scores on picked maps
+	defaultBestOf      = 3
+	defaultMatchBans   = 1 // Number of maps each player bans by default
+	maxBestOf          = 15
+
+	// How many of a player's scores on a picked map to look through. The search is
+	// by song name so scores on other songs with the same name can show up too
+	matchScoreLookupCount = 25
+)
+
+var (
+	reMention  = regexp.MustCompile(`^<@!?(\d+)>$`)
+	reMatchMap = regexp.MustCompile(`(?i)^(.+?)(?:\s+at\s+(\d*\.?\d+x?))?$`)
+)
+
+// CmdMatch runs a 1v1 match between two registered players in the channel. An admin
+// creates the match and its map pool, then the players take turns banning and picking
+// maps. Scores on picked maps are detected automatically
+func CmdMatch(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	usage := "Usage: match [status | create <player> <player> [best of] [bans] | pool add <song> [at <rate>] | " +
+		"pool remove <number> | ban <number> | pick <number> | cancel]"
+
+	if len(args) == 1 {
+		args = append(args, "status")
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "create":
+		createMatch(bot, m, args[2:])
+		return
+	case "status", "pool", "ban", "pick", "cancel":
+	default:
+		bot.Session.ChannelMessageSend(m.ChannelID, usage)
+		return
+	}
+
+	match, err := bot.Matches.GetActive(m.ChannelID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if match == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "There's no match in this channel. Create one with `match create`.")
+		return
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "status":
+		// Post a new embed so the match doesn't get lost further up the channel
+		match.MessageID.Valid = false
+		err = updateMatchEmbed(bot, match)
+	case "pool":
+		err = editMatchPool(bot, m, match, args[2:])
+	case "ban":
+		err = chooseMatchMap(bot, m, match, args[2:], model.MapBanned)
+	case "pick":
+		err = chooseMatchMap(bot, m, match, args[2:], model.MapPicked)
+	case "cancel":
+		err = cancelMatch(bot, m, match)
+	}
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+	}
+}
+
+func createMatch(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	if !isServerAdmin(bot, m) {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You need the Manage Server permission to do that.")
+		return
+	} else if len(args) < 2 || len(args) > 4 {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Usage: match create <player> <player> [best of] [bans]")
+		return
+	}
+
+	if match, err := bot.Matches.GetActive(m.ChannelID); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if match != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "There's already a match in this channel. Use `match cancel` to cancel it first.")
+		return
+	}
+
+	var players [2]*model.EtternaUser
+
+	for i := range players {
+		user, err := getRegisteredPlayer(bot, m.GuildID, args[i])
+
+		if err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		}
+
+		players[i] = user
+	}
+
+	if players[0].ID == players[1].ID {
+		bot.Session.ChannelMessageSend(m.ChannelID, "A player can't play against themselves.")
+		return
+	}
+
+	match := &model.Match{
+		ServerID:  m.GuildID,
+		ChannelID: m.ChannelID,
+		Player1ID: players[0].ID,
+		Player2ID: players[1].ID,
+		BestOf:    defaultBestOf,
+		Bans:      defaultMatchBans,
+		Status:    model.MatchBanning,
+		TurnID:    players[0].ID,
+	}
+
+	if len(args) > 2 {
+		n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(args[2]), "bo"))
+
+		if err != nil || n < 1 || n > maxBestOf || n%2 == 0 {
+			bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Best of must be an odd number up to %d, e.g. `bo5`", maxBestOf))
+			return
+		}
+
+		match.BestOf = n
+	}
+
+	if len(args) > 3 {
+		n, err := strconv.Atoi(args[3])
+
+		if err != nil || n < 0 || n > maxBestOf {
+			bot.Session.ChannelMessageSend(m.ChannelID, "Bans must be the number of maps each player bans, e.g. `1`")
+			return
+		}
+
+		match.Bans = n
+	}
+
+	if match.Bans == 0 {
+		match.Status = model.MatchPicking
+	}
+
+	if err := bot.Matches.Save(match); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	if err := updateMatchEmbed(bot, match); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, "Add maps to the pool with `match pool add <song> [at <rate>]`.")
+}
+
+// getRegisteredPlayer looks up the etterna user that is registered in the server from
+// a discord mention or an etterna username
+func getRegisteredPlayer(bot *eb.Bot, serverID, arg string) (*model.EtternaUser, error) {
+	if match := reMention.FindStringSubmatch(arg); match != nil {
+		user, err := bot.Users.GetRegisteredUser(serverID, match[1])
+
+		if err != nil {
+			return nil, err
+		} else if user == nil {
+			return nil, errors.New(arg + " is not registered with an Etterna user.")
+		}
+
+		return user, nil
+	}
+
+	user, err := bot.Users.GetUsername(arg)
+
+	if err != nil {
+		return nil, err
+	} else if user == nil {
+		return nil, fmt.Errorf("%s is not registered in this server.", arg)
+	}
+
+	discordID, err := bot.Users.GetRegisteredDiscordUserID(serverID, user.Username)
+
+	if err != nil {
+		return nil, err
+	} else if discordID == "" {
+		return nil, fmt.Errorf("%s is not registered in this server.", user.Username)
+	}
+
+	return user, nil
+}
+
+func cancelMatch(bot *eb.Bot, m *discordgo.MessageCreate, match *model.Match) error {
+	if !isServerAdmin(bot, m) {
+		return errors.New("You need the Manage Server permission to do that.")
+	}
+
+	if err := bot.Matches.Delete(match.ID); err != nil {
+		return err
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Cancelled match #%d.", match.ID))
+
+	return nil
+}
+
+// editMatchPool adds or removes a map from the pool. The pool can't be changed once
+// the players have started banning or picking
+func editMatchPool(bot *eb.Bot, m *discordgo.MessageCreate, match *model.Match, args []string) error {
+	usage := errors.New("Usage: match pool add <song> [at <rate>] | match pool remove <number>")
+
+	if !isServerAdmin(bot, m) {
+		return errors.New("You need the Manage Server permission to do that.")
+	} else if len(args) < 2 {
+		return usage
+	}
+
+	maps, err := bot.Matches.GetMaps(match.ID)
+
+	if err != nil {
+		return err
+	}
+
+	for _, mp := range maps {
+		if mp.Status != model.MapPool {
+			return errors.New("The map pool can't be changed once the players have started banning and picking.")
+		}
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "add":
+		parts := reMatchMap.FindStringSubmatch(strings.Join(args[1:], " "))
+
+		if parts == nil {
+			return usage
+		}
+
+		rate := 1.0
+
+		if parts[2] != "" {
+			if rate, err = parseRate(parts[2]); err != nil {
+				return err
+			}
+		}
+
+		song, err := findSong(bot, parts[1])
+
+		if err != nil {
+			return err
+		} else if song == nil {
+			return errors.New("Could not find a song with that name. Use the `song` command to look up its ID.")
+		}
+
+		if err := bot.Matches.SaveMap(&model.MatchMap{
+			MatchID: match.ID,
+			SongID:  song.EtternaID,
+			Rate:    rate,
+			Status:  model.MapPool,
+		}); err != nil {
+			return err
+		}
+
+	case "remove":
+		n, err := strconv.Atoi(args[1])
+
+		if err != nil || n < 1 || n > len(maps) {
+			return errors.New("There's no map with that number in the pool.")
+		}
+
+		if err := bot.Matches.DeleteMap(maps[n-1].ID); err != nil {
+			return err
+		}
+
+	default:
+		return usage
+	}
+
+	return updateMatchEmbed(bot, match)
+}
+
+// chooseMatchMap bans or picks a map from the pool. Only the player whose turn it is
+// can ban or pick
+func chooseMatchMap(bot *eb.Bot, m *discordgo.MessageCreate, match *model.Match, args []string, status string) error {
+	verb := "ban"
+
+	if status == model.MapPicked {
+		verb = "pick"
+	}
+
+	if len(args) != 1 {
+		return fmt.Errorf("Usage: match %s <number>", verb)
+	}
+
+	user, err := bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID)
+
+	if err != nil {
+		return err
+	} else if user == nil || (user.ID != match.Player1ID && user.ID != match.Player2ID) {
+		return errors.New("You're not playing in this match.")
+	} else if user.ID != match.TurnID {
+		return errors.New("It's not your turn.")
+	}
+
+	if status == model.MapBanned && match.Status != model.MatchBanning {
+		return errors.New("Maps can't be banned right now.")
+	} else if status == model.MapPicked && match.Status != model.MatchPicking {
+		return errors.New("Maps can't be picked right now.")
+	}
+
+	maps, err := bot.Matches.GetMaps(match.ID)
+
+	if err != nil {
+		return err
+	}
+
+	n, err := strconv.Atoi(args[0])
+
+	if err != nil || n < 1 || n > len(maps) {
+		return errors.New("There's no map with that number in the pool.")
+	} else if maps[n-1].Status != model.MapPool {
+		return fmt.Errorf("That map was already %s.", maps[n-1].Status)
+	}
+
+	remaining := 0
+	banned := 0
+
+	for _, mp := range maps {
+		if mp.Status == model.MapPool {
+			remaining++
+		} else if mp.Status == model.MapBanned {
+			banned++
+		}
+	}
+
+	// There needs to be something left to pick after the bans
+	if status == model.MapBanned && remaining <= 1 {
+		return errors.New("There aren't enough maps in the pool to ban any more. Add more with `match pool add`.")
+	}
+
+	mp := maps[n-1]
+	mp.Status = status
+	mp.ChosenBy.Int64 = int64(user.ID)
+	mp.ChosenBy.Valid = true
+
+	if status == model.MapBanned {
+		banned++
+
+		if banned == 2*match.Bans {
+			match.Status = model.MatchPicking
+		}
+
+		match.TurnID = match.Opponent(user.ID)
+	} else {
+		// Remember each player's latest score on the map so only new scores count
+		now := time.Now().UTC()
+		mp.PickedAt = &now
+
+		for i, id := range []uint{match.Player1ID, match.Player2ID} {
+			key, err := getLatestMapScoreKey(bot, id, mp)
+
+			if err != nil {
+				return err
+			}
+
+			if i == 0 {
+				mp.Player1LastKey = key
+			} else {
+				mp.Player2LastKey = key
+			}
+		}
+
+		match.Status = model.MatchPlaying
+	}
+
+	if err := bot.Matches.SaveMap(mp); err != nil {
+		return err
+	} else if err := bot.Matches.Save(match); err != nil {
+		return err
+	}
+
+	bot.Session.MessageReactionAdd(m.ChannelID, m.ID, "👍")
+
+	return updateMatchEmbed(bot, match)
+}
+
+// getLatestMapScoreKey returns the key of the user's most recent score on the map at
+// the picked rate
+func getLatestMapScoreKey(bot *eb.Bot, userID uint, mp *model.MatchMap) (sql.NullString, error) {
+	var key sql.NullString
+
+	user, err := bot.Users.GetID(userID)
+
+	if err != nil {
+		return key, err
+	} else if user == nil {
+		return key, errors.New("Failed to look up a player in the match.")
+	}
+
+	scores, err := getMapScores(bot, user.EtternaID, mp)
+
+	if err != nil {
+		return key, err
+	} else if len(scores) > 0 {
+		key.String = scores[0].Key
+		key.Valid = true
+	}
+
+	return key, nil
+}
+
+// getMapScores returns the user's scores on the map at the picked rate, newest first
+func getMapScores(bot *eb.Bot, etternaID int, mp *model.MatchMap) ([]etterna.Score, error) {
+	song, err := getSongOrCreate(bot, mp.SongID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scores, err := bot.API.GetScores(etternaID, song.Name, matchScoreLookupCount, 0, etterna.SortDate, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var mapScores []etterna.Score
+
+	for _, s := range scores {
+		if s.Song.ID == mp.SongID && math.Round(s.Rate*100) == math.Round(mp.Rate*100) {
+			mapScores = append(mapScores, s)
+		}
+	}
+
+	return mapScores, nil
+}
+
+// firstScoreSincePick returns the first score the user played on the map after it was
+// picked, or nil if they haven't played it yet
+func firstScoreSincePick(bot *eb.Bot, etternaID int, mp *model.MatchMap, lastKey sql.NullString) (*etterna.Score, error) {
+	scores, err := getMapScores(bot, etternaID, mp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Score dates only have the day and EO's timezone may be behind UTC, so allow
+	// scores from the day before the pick. The key of the latest score on the map
+	// at the time of the pick marks where the new scores end
+	earliest := mp.PickedAt.UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+
+	var first *etterna.Score
+
+	for i, s := range scores {
+		if (lastKey.Valid && s.Key == lastKey.String) || s.Date.Before(earliest) {
+			break
+		}
+
+		first = &scores[i]
+	}
+
+	return first, nil
+}
+
+// CheckMatches looks for scores on the picked map of every match that's being played.
+// Once both players have played the map, the player with the higher accuracy wins the
+// round. Only the first score each player plays on the map (at the picked rate) counts
+func CheckMatches(bot *eb.Bot) {
+	matches, err := bot.Matches.GetPlaying()
+
+	if err != nil {
+		fmt.Println("Failed to look up matches", err)
+		return
+	}
+
+	for _, match := range matches {
+		if err := checkMatch(bot, match); err != nil {
+			fmt.Println("Failed to check match", match.ID, err)
+		}
+	}
+}
+
+func checkMatch(bot *eb.Bot, match *model.Match) error {
+	maps, err := bot.Matches.GetMaps(match.ID)
+
+	if err != nil {
+		return err
+	}
+
+	var current *model.MatchMap
+
+	for _, mp := range maps {
+		if mp.Status == model.MapPicked && !mp.WinnerID.Valid {
+			current = mp
+		}
+	}
+
+	if current == nil {
+		return nil
+	}
+
+	type submission struct {
+		userID   uint
+		lastKey  *sql.NullString
+		accuracy *sql.NullFloat64
+	}
+
+	found := false
+
+	for _, p := range []submission{
+		{match.Player1ID, &current.Player1LastKey, &current.Player1Accuracy},
+		{match.Player2ID, &current.Player2LastKey, &current.Player2Accuracy},
+	} {
+		if p.accuracy.Valid {
+			continue
+		}
+
+		user, err := bot.Users.GetID(p.userID)
+
+		if err != nil {
+			return err
+		} else if user == nil {
+			continue
+		}
+
+		s, err := firstScoreSincePick(bot, user.EtternaID, current, *p.lastKey)
+
+		if err != nil {
+			return err
+		} else if s == nil {
+			continue
+		}
+
+		p.lastKey.String = s.Key
+		p.lastKey.Valid = true
+		p.accuracy.Float64 = s.Accuracy
+		p.accuracy.Valid = true
+		found = true
+	}
+
+	if !found {
+		return nil
+	}
+
+	if current.Player1Accuracy.Valid && current.Player2Accuracy.Valid {
+		finishMatchRound(bot, match, maps, current)
+	}
+
+	if err := bot.Matches.SaveMap(current); err != nil {
+		return err
+	} else if err := bot.Matches.Save(match); err != nil {
+		return err
+	}
+
+	return updateMatchEmbed(bot, match)
+}
+
+// finishMatchRound decides the winner of the round once both players have played the
+// picked map. The loser picks the next map. If the accuracies are tied the map has to
+// be played again
+func finishMatchRound(bot *eb.Bot, match *model.Match, maps []*model.MatchMap, current *model.MatchMap) {
+	acc1 := current.Player1Accuracy.Float64
+	acc2 := current.Player2Accuracy.Float64
+
+	if acc1 == acc2 {
+		current.Player1Accuracy.Valid = false
+		current.Player2Accuracy.Valid = false
+		bot.Session.ChannelMessageSend(match.ChannelID, fmt.Sprintf("Both players got %.2f%%! Play the map again.", acc1))
+		return
+	}
+
+	winner := match.Player1ID
+
+	if acc2 > acc1 {
+		winner = match.Player2ID
+	}
+
+	current.WinnerID.Int64 = int64(winner)
+	current.WinnerID.Valid = true
+
+	wins1, wins2 := countMatchWins(match, maps)
+	remaining := 0
+
+	for _, mp := range maps {
+		if mp.Status == model.MapPool {
+			remaining++
+		}
+	}
+
+	if wins1 > match.BestOf/2 || wins2 > match.BestOf/2 || remaining == 0 {
+		match.Status = model.MatchFinished
+	} else {
+		match.Status = model.MatchPicking
+		match.TurnID = match.Opponent(winner)
+	}
+
+	message := fmt.Sprintf("**%s** wins the round (%.2f%% vs. %.2f%%)! The score is %d – %d.",
+		getPlayerName(bot, winner), math.Max(acc1, acc2), math.Min(acc1, acc2), wins1, wins2)
+
+	// The pool can run out before anyone wins the majority of the rounds
+	if match.Status == model.MatchFinished && wins1 == wins2 {
+		message += " The pool ran out, so the match is a draw."
+	} else if match.Status == model.MatchFinished && wins1 > wins2 {
+		message += fmt.Sprintf(" 🏆 **%s** wins the match!", getPlayerName(bot, match.Player1ID))
+	} else if match.Status == model.MatchFinished {
+		message += fmt.Sprintf(" 🏆 **%s** wins the match!", getPlayerName(bot, match.Player2ID))
+	}
+
+	bot.Session.ChannelMessageSend(match.ChannelID, message)
+}
+
+// getPlayerName returns the username of the etterna user with the given ID
+func getPlayerName(bot *eb.Bot, userID uint) string {
+	if u, err := bot.Users.GetID(userID); err == nil && u != nil {
+		return u.Username
+	}
+
+	return fmt.Sprintf("user #%d", userID)
+}
+
+// countMatchWins returns the number of rounds each player has won
+func countMatchWins(match *model.Match, maps []*model.MatchMap) (int, int) {
+	wins1, wins2 := 0, 0
+
+	for _, mp := range maps {
+		if !mp.WinnerID.Valid {
+			continue
+		} else if uint(mp.WinnerID.Int64) == match.Player1ID {
+			wins1++
+		} else {
+			wins2++
+		}
+	}
+
+	return wins1, wins2
+}
+
+// updateMatchEmbed edits the match's embed with the latest state of the match. If the
+// embed hasn't been posted (or can't be edited), a new one is posted
+func updateMatchEmbed(bot *eb.Bot, match *model.Match) error {
+	embed, err := getMatchEmbed(bot, match)
+
+	if err != nil {
+		return err
+	}
+
+	if match.MessageID.Valid {
+		if _, err := bot.Session.ChannelMessageEditEmbed(match.ChannelID, match.MessageID.String, embed); err == nil {
+			return nil
+		}
+	}
+
+	msg, err := bot.Session.ChannelMessageSendEmbed(match.ChannelID, embed)
+
+	if err != nil {
+		return err
+	}
+
+	match.MessageID.String = msg.ID
+	match.MessageID.Valid = true
+
+	return bot.Matches.Save(match)
+}
+
+func getMatchEmbed(bot *eb.Bot, match *model.Match) (*discordgo.MessageEmbed, error) {
+	maps, err := bot.Matches.GetMaps(match.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[uint]string{
+		match.Player1ID: getPlayerName(bot, match.Player1ID),
+		match.Player2ID: getPlayerName(bot, match.Player2ID),
+	}
+
+	name1 := names[match.Player1ID]
+	name2 := names[match.Player2ID]
+	wins1, wins2 := countMatchWins(match, maps)
+
+	description := fmt.Sprintf("**%s** %d – %d **%s**\n\n", name1, wins1, wins2, name2)
+
+	switch match.Status {
+	case model.MatchBanning:
+		description += fmt.Sprintf("🚫 **%s** bans next: `match ban <number>`", names[match.TurnID])
+	case model.MatchPicking:
+		description += fmt.Sprintf("👉 **%s** picks next: `match pick <number>`", names[match.TurnID])
+	case model.MatchPlaying:
+		description += "🎮 Waiting for both players to play the picked map"
+	case model.MatchFinished:
+		description += "🏁 The match is over"
+	}
+
+	pool := ""
+
+	for i, mp := range maps {
+		name := fmt.Sprintf("song #%d", mp.SongID)
+
+		if song, err := bot.Songs.Get(mp.SongID); err == nil && song != nil {
+			name = song.Name
+		}
+
+		line := fmt.Sprintf("%s (%sx)", name, formatRate(mp.Rate))
+		chosenBy := names[uint(mp.ChosenBy.Int64)]
+
+		switch {
+		case mp.Status == model.MapBanned:
+			line = fmt.Sprintf("~~%s~~ banned by %s", line, chosenBy)
+		case mp.Status == model.MapPicked && mp.WinnerID.Valid:
+			line = fmt.Sprintf("%s — won by **%s** (%.2f%% vs. %.2f%%)", line, names[uint(mp.WinnerID.Int64)],
+				mp.Player1Accuracy.Float64, mp.Player2Accuracy.Float64)
+		case mp.Status == model.MapPicked:
+			line = fmt.Sprintf("▶ **%s** picked by %s — %s: %s, %s: %s", line, chosenBy,
+				name1, formatMatchAccuracy(mp.Player1Accuracy), name2, formatMatchAccuracy(mp.Player2Accuracy))
+		}
+
+		pool += fmt.Sprintf("**%d.** %s\n", i+1, line)
+	}
+
+	if pool == "" {
+		pool = "No maps yet. Add them with `match pool add <song> [at <rate>]`."
+	}
+
+	return &discordgo.MessageEmbed{
+		Color:       embedColor,
+		Title:       fmt.Sprintf("Match #%d: %s vs. %s (best of %d)", match.ID, name1, name2, match.BestOf),
+		Description: description,
+		Fields: []*discordgo.MessageEmbedField{
+			&discordgo.MessageEmbedField{
+				Name:  "Map pool",
+				Value: pool,
+			},
+		},
+	}, nil
+}
+
+func formatMatchAccuracy(acc sql.NullFloat64) string {
+	if !acc.Valid {
+		return "waiting"
+	}
+
+	return fmt.Sprintf("%.2f%%", acc.Float64)
+}
@@ -0,0 +1,311 @@
+package bot
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/bwmarrin/discordgo"
+)
+
+const membersPageSize = 25
+
+var (
+	reDiscordID = regexp.MustCompile(`^\d+$`)
+)
+
+// CmdMembers lets admins manage who is registered in the server
+func CmdMembers(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	usage := "Usage: members [list [page] | register <member> <username> | unregister <member> | transfer <username> <member> | prune]"
+
+	if !isServerAdmin(bot, m) {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You need the Manage Server permission to do that.")
+		return
+	}
+
+	if len(args) == 1 {
+		listMembers(bot, m, 1)
+		return
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "list":
+		page := 1
+
+		if len(args) > 2 {
+			n, err := strconv.Atoi(args[2])
+
+			if err != nil || n < 1 {
+				bot.Session.ChannelMessageSend(m.ChannelID, usage)
+				return
+			}
+
+			page = n
+		}
+
+		listMembers(bot, m, page)
+	case "register":
+		if len(args) != 4 {
+			bot.Session.ChannelMessageSend(m.ChannelID, usage)
+			return
+		}
+
+		forceRegister(bot, m, args[2], args[3])
+	case "unregister":
+		if len(args) != 3 {
+			bot.Session.ChannelMessageSend(m.ChannelID, usage)
+			return
+		}
+
+		forceUnregister(bot, m, args[2])
+	case "transfer":
+		if len(args) != 4 {
+			bot.Session.ChannelMessageSend(m.ChannelID, usage)
+			return
+		}
+
+		transferRegistration(bot, m, args[2], args[3])
+	case "prune":
+		pruneMembers(bot, m)
+	default:
+		bot.Session.ChannelMessageSend(m.ChannelID, usage)
+	}
+}
+
+func listMembers(bot *eb.Bot, m *discordgo.MessageCreate, page int) {
+	registrations, err := bot.Users.GetServerRegistrations(m.GuildID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if len(registrations) == 0 {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Nobody is registered in this server.")
+		return
+	}
+
+	pages := (len(registrations) + membersPageSize - 1) / membersPageSize
+
+	if page > pages {
+		page = pages
+	}
+
+	start := (page - 1) * membersPageSize
+	end := start + membersPageSize
+
+	if end > len(registrations) {
+		end = len(registrations)
+	}
+
+	description := ""
+
+	for _, r := range registrations[start:end] {
+		description += fmt.Sprintf("%s — <@%s>\n", r.Username, r.DiscordUserID)
+	}
+
+	bot.Session.ChannelMessageSendEmbed(m.ChannelID, &discordgo.MessageEmbed{
+		Color:       embedColor,
+		Title:       fmt.Sprintf("Registered members (%d)", len(registrations)),
+		Description: description,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d/%d", page, pages),
+		},
+	})
+}
+
+// forceRegister registers the member as the etterna user in this server, replacing
+// their current registration
+func forceRegister(bot *eb.Bot, m *discordgo.MessageCreate, memberArg, username string) {
+	discordID := parseMemberID(memberArg)
+
+	if discordID == "" {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Mention the member or use their ID.")
+		return
+	}
+
+	holder, err := bot.Users.GetRegisteredDiscordUserID(m.GuildID, username)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if holder == discordID {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("<@%s> is already registered as '%s'.", discordID, username))
+		return
+	} else if holder != "" {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("<@%s> is already registered as '%s'. "+
+			"Use `members transfer` instead.", holder, username))
+		return
+	}
+
+	user, err := getUserOrCreate(bot, username, false)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	if err := replaceRegistration(bot, m.GuildID, discordID, user.Username); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("<@%s> is now registered as '%s' in this server.", discordID, user.Username))
+}
+
+func forceUnregister(bot *eb.Bot, m *discordgo.MessageCreate, memberArg string) {
+	discordID := parseMemberID(memberArg)
+
+	if discordID == "" {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Mention the member or use their ID.")
+		return
+	}
+
+	if ok, err := bot.Users.Unregister(m.GuildID, discordID); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if !ok {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("<@%s> isn't registered in this server.", discordID))
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("<@%s> is no longer registered in this server.", discordID))
+}
+
+// transferRegistration moves the etterna user from whoever is registered as them in
+// this server to the member
+func transferRegistration(bot *eb.Bot, m *discordgo.MessageCreate, username, memberArg string) {
+	discordID := parseMemberID(memberArg)
+
+	if discordID == "" {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Mention the member or use their ID.")
+		return
+	}
+
+	user, err := bot.Users.GetUsername(username)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if user == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Nobody is registered as '%s' in this server.", username))
+		return
+	}
+
+	holder, err := bot.Users.GetRegisteredDiscordUserID(m.GuildID, user.Username)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if holder == "" {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Nobody is registered as '%s' in this server. "+
+			"Use `members register` instead.", user.Username))
+		return
+	} else if holder == discordID {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("<@%s> is already registered as '%s'.", discordID, user.Username))
+		return
+	}
+
+	if _, err := bot.Users.Unregister(m.GuildID, holder); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	if err := replaceRegistration(bot, m.GuildID, discordID, user.Username); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Transferred '%s' from <@%s> to <@%s> in this server.",
+		user.Username, holder, discordID))
+}
+
+// replaceRegistration unregisters the member in the server and registers them as the
+// etterna user instead
+func replaceRegistration(bot *eb.Bot, serverID, discordID, username string) error {
+	if _, err := bot.Users.Unregister(serverID, discordID); err != nil {
+		return err
+	}
+
+	ok, err := bot.Users.Register(username, serverID, discordID)
+
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("Someone else is already registered as '%s' in this server.", username)
+	}
+
+	return nil
+}
+
+// pruneMembers removes the registrations of members who left the server. Members who
+// leave while the bot is running are removed automatically, this catches anyone who
+// left while it wasn't
+func pruneMembers(bot *eb.Bot, m *discordgo.MessageCreate) {
+	registrations, err := bot.Users.GetServerRegistrations(m.GuildID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	var removed []string
+
+	for _, r := range registrations {
+		if _, err := bot.Session.State.Member(m.GuildID, r.DiscordUserID); err == nil {
+			continue
+		}
+
+		_, err := bot.Session.GuildMember(m.GuildID, r.DiscordUserID)
+
+		if err == nil {
+			continue
+		} else if !isUnknownMember(err) {
+			// Don't remove anyone if discord had a hiccup
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		}
+
+		if _, err := bot.Users.RemoveMember(m.GuildID, r.DiscordUserID); err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		}
+
+		removed = append(removed, r.Username)
+	}
+
+	if len(removed) == 0 {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Everyone who is registered is still in the server.")
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Removed %d registrations for members who left: %s",
+		len(removed), strings.Join(removed, ", ")))
+}
+
+// parseMemberID returns the discord ID from a mention or ID, or an empty string if
+// the argument is neither
+func parseMemberID(arg string) string {
+	if match := reMention.FindStringSubmatch(arg); match != nil {
+		return match[1]
+	} else if reDiscordID.MatchString(arg) {
+		return arg
+	}
+
+	return ""
+}
+
+// isUnknownMember returns whether the error from discord means the user isn't in the
+// server
+func isUnknownMember(err error) bool {
+	restErr, ok := err.(*discordgo.RESTError)
+
+	if !ok {
+		return false
+	} else if restErr.Message != nil {
+		return restErr.Message.Code == discordgo.ErrCodeUnknownMember
+	}
+
+	return restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
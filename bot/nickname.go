@@ -0,0 +1,327 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	maxNicknameLength         = 32               // Discord's limit on nickname length
+	maxNicknameTemplateLength = 64               // Size of the template column
+	nicknameInterval          = 10 * time.Minute // Minimum time between automatic nickname updates for a member
+)
+
+var (
+	// When each member's nickname was last updated, and the updates that are waiting for
+	// nicknameInterval to pass. Both are keyed by server and discord user ID
+	nicknameUpdates   = make(map[string]time.Time)
+	pendingNicknames  = make(map[string]pendingNickname)
+	nicknameUpdatesMu sync.Mutex
+)
+
+type pendingNickname struct {
+	serverID  string
+	discordID string
+	username  string
+}
+
+// CmdNickname shows or sets the template used for registered members' nicknames.
+// Nicknames are updated whenever a player's ratings are looked up
+func CmdNickname(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate, args []string) {
+	usage := "Usage: nickname [<template> | off | sync]. Templates can use {discord}, {username}, {overall}, {rank}, {country} and {flag}, e.g. `nickname {discord} [{overall}]`"
+
+	if len(args) == 1 {
+		if !server.NicknameTemplate.Valid {
+			bot.Session.ChannelMessageSend(m.ChannelID, "Nickname sync is disabled. "+usage)
+			return
+		}
+
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Nickname template: `%s`", server.NicknameTemplate.String))
+		return
+	}
+
+	if !isServerAdmin(bot, m) {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You need the Manage Server permission to do that.")
+		return
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "off":
+		server.NicknameTemplate.Valid = false
+
+		if err := bot.Servers.Save(server); err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		}
+
+		bot.Session.ChannelMessageSend(m.ChannelID, "Nicknames will no longer be updated. Nicknames that were already set will stay as they are.")
+	case "sync":
+		syncServerNicknames(bot, server, m)
+	default:
+		template := strings.Join(args[1:], " ")
+
+		if len(template) > maxNicknameTemplateLength {
+			bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("The template can't be longer than %d characters.", maxNicknameTemplateLength))
+			return
+		} else if !strings.Contains(template, "{") {
+			bot.Session.ChannelMessageSend(m.ChannelID, usage)
+			return
+		}
+
+		if !canManageNicknames(bot, m.ChannelID) {
+			bot.Session.ChannelMessageSend(m.ChannelID, "I need the Manage Nicknames permission to do that.")
+			return
+		}
+
+		server.NicknameTemplate.String = template
+		server.NicknameTemplate.Valid = true
+
+		if err := bot.Servers.Save(server); err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		}
+
+		msg := "Nickname template updated. Use `nickname sync` to update everyone now."
+
+		// Show an example using the admin's own account if they're registered
+		if user, err := bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID); err == nil && user != nil {
+			msg += fmt.Sprintf(" Your nickname will look like: **%s**", formatNickname(template, m.Author.Username, user))
+		}
+
+		bot.Session.ChannelMessageSend(m.ChannelID, msg)
+	}
+}
+
+// syncServerNicknames updates the nickname of every player registered in the server
+// using their cached ratings and ranks
+func syncServerNicknames(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate) {
+	if !server.NicknameTemplate.Valid {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Nickname sync is disabled. Set a template first with `nickname <template>`.")
+		return
+	} else if !canManageNicknames(bot, m.ChannelID) {
+		bot.Session.ChannelMessageSend(m.ChannelID, "I need the Manage Nicknames permission to do that.")
+		return
+	}
+
+	users, err := bot.Users.GetRegisteredUsers(m.GuildID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	synced := 0
+
+	for _, u := range users {
+		discordID, err := bot.Users.GetRegisteredDiscordUserID(m.GuildID, u.Username)
+
+		if err != nil || discordID == "" {
+			continue
+		}
+
+		if err := updateMemberNickname(bot, server, discordID, u); err != nil {
+			fmt.Println("Failed to sync nickname", m.GuildID, u.Username, err)
+			continue
+		}
+
+		synced++
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Updated the nicknames of %d/%d players. "+
+		"I can't change the nickname of the server owner or anyone with a higher role than me.", synced, len(users)))
+}
+
+// syncNicknames updates the user's nickname in every server they're registered in that
+// has nickname sync enabled. Should be called whenever the user's ratings or ranks are
+// updated. Each member is updated at most once per nicknameInterval, later updates are
+// applied by SyncPendingNicknames
+func syncNicknames(bot *eb.Bot, user *model.EtternaUser) {
+	registrations, err := bot.Users.GetRegistrations(user.Username)
+
+	if err != nil {
+		fmt.Println("Failed to look up registrations", user.Username, err)
+		return
+	}
+
+	for _, r := range registrations {
+		server, err := bot.Servers.Get(r.ServerID)
+
+		if err != nil {
+			fmt.Println("Failed to look up server", r.ServerID, err)
+			continue
+		} else if server == nil || !server.NicknameTemplate.Valid {
+			continue
+		}
+
+		key := r.ServerID + ":" + r.DiscordUserID
+
+		nicknameUpdatesMu.Lock()
+		last, exists := nicknameUpdates[key]
+
+		if exists && time.Since(last) < nicknameInterval {
+			pendingNicknames[key] = pendingNickname{r.ServerID, r.DiscordUserID, user.Username}
+			nicknameUpdatesMu.Unlock()
+			continue
+		}
+
+		nicknameUpdatesMu.Unlock()
+
+		if err := updateMemberNickname(bot, server, r.DiscordUserID, user); err != nil {
+			fmt.Println("Failed to sync nickname", r.ServerID, user.Username, err)
+		}
+	}
+}
+
+// SyncPendingNicknames applies the nickname updates that were held back because the
+// member's nickname had been updated recently. Uses the latest cached ratings and ranks
+func SyncPendingNicknames(bot *eb.Bot) {
+	var due []pendingNickname
+
+	nicknameUpdatesMu.Lock()
+
+	for key, p := range pendingNicknames {
+		if time.Since(nicknameUpdates[key]) >= nicknameInterval {
+			due = append(due, p)
+			delete(pendingNicknames, key)
+		}
+	}
+
+	// Members updated longer ago than the interval don't need to be remembered
+	for key, last := range nicknameUpdates {
+		if time.Since(last) >= nicknameInterval {
+			delete(nicknameUpdates, key)
+		}
+	}
+
+	nicknameUpdatesMu.Unlock()
+
+	for _, p := range due {
+		// The member may have unregistered or the server turned off nickname sync
+		discordID, err := bot.Users.GetRegisteredDiscordUserID(p.serverID, p.username)
+
+		if err != nil {
+			fmt.Println("Failed to look up registration", p.serverID, p.username, err)
+			continue
+		} else if discordID != p.discordID {
+			continue
+		}
+
+		server, err := bot.Servers.Get(p.serverID)
+
+		if err != nil {
+			fmt.Println("Failed to look up server", p.serverID, err)
+			continue
+		} else if server == nil || !server.NicknameTemplate.Valid {
+			continue
+		}
+
+		user, err := bot.Users.GetUsername(p.username)
+
+		if err != nil {
+			fmt.Println("Failed to look up user", p.username, err)
+			continue
+		} else if user == nil {
+			continue
+		}
+
+		if err := updateMemberNickname(bot, server, p.discordID, user); err != nil {
+			fmt.Println("Failed to sync nickname", p.serverID, p.username, err)
+		}
+	}
+}
+
+// updateMemberNickname sets the member's nickname from the server's template. Does
+// nothing if the nickname is already up to date or the member is the server owner,
+// since discord doesn't allow bots to change the owner's nickname
+func updateMemberNickname(bot *eb.Bot, server *model.DiscordServer, discordID string, user *model.EtternaUser) error {
+	if guild, err := bot.Session.State.Guild(server.ServerID); err == nil && guild.OwnerID == discordID {
+		return nil
+	}
+
+	member, err := bot.Session.GuildMember(server.ServerID, discordID)
+
+	if err != nil {
+		return err
+	}
+
+	nickname := formatNickname(server.NicknameTemplate.String, member.User.Username, user)
+
+	if nickname == member.Nick {
+		return nil
+	}
+
+	if err := bot.Session.GuildMemberNickname(server.ServerID, discordID, nickname); err != nil {
+		return err
+	}
+
+	key := server.ServerID + ":" + discordID
+
+	nicknameUpdatesMu.Lock()
+	nicknameUpdates[key] = time.Now()
+	delete(pendingNicknames, key)
+	nicknameUpdatesMu.Unlock()
+
+	return nil
+}
+
+// formatNickname fills in the template's placeholders and trims the result to the
+// longest nickname discord allows
+func formatNickname(template, discordName string, user *model.EtternaUser) string {
+	nickname := strings.NewReplacer(
+		"{discord}", discordName,
+		"{username}", user.Username,
+		"{overall}", fmt.Sprintf("%.2f", user.MSDOverall),
+		"{rank}", fmt.Sprintf("#%d", user.RankOverall),
+		"{country}", user.CountryCode,
+		"{flag}", countryFlag(user.CountryCode),
+	).Replace(template)
+
+	nickname = strings.Join(strings.Fields(nickname), " ")
+
+	if runes := []rune(nickname); len(runes) > maxNicknameLength {
+		nickname = strings.TrimSpace(string(runes[:maxNicknameLength]))
+	}
+
+	return nickname
+}
+
+// countryFlag returns the flag emoji for a two letter country code, or an empty
+// string if the code isn't valid
+func countryFlag(code string) string {
+	code = strings.ToUpper(code)
+
+	if len(code) != 2 {
+		return ""
+	}
+
+	flag := ""
+
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+
+		// Flags are made up of the regional indicator symbols for each letter
+		flag += string(rune(0x1F1E6 + c - 'A'))
+	}
+
+	return flag
+}
+
+// canManageNicknames returns whether the bot has permission to change nicknames
+func canManageNicknames(bot *eb.Bot, channelID string) bool {
+	perms, err := bot.Session.UserChannelPermissions(bot.Session.State.User.ID, channelID)
+
+	if err != nil {
+		fmt.Println("Failed to look up permissions", channelID, err)
+		return false
+	}
+
+	return perms&discordgo.PermissionAdministrator != 0 || perms&discordgo.PermissionManageNicknames != 0
+}
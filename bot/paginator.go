@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	defaultPageSize  = 10               // Number of lines to show on each page
+	paginatorTimeout = 15 * time.Minute // How long a message can be paged through
+
+	emojiPrevPage = "◀"
+	emojiNextPage = "▶"
+)
+
+// paginator keeps track of a message whose description is split across pages.
+// Users can switch pages by reacting to the message
+type paginator struct {
+	channelID string
+	embed     *discordgo.MessageEmbed
+	expires   time.Time
+	page      int
+	pages     []string
+}
+
+// Active paginators, keyed by message ID
+var paginators = struct {
+	sync.Mutex
+	m map[string]*paginator
+}{m: make(map[string]*paginator)}
+
+// sendPaginatedEmbed sends the embed with the lines split into pages in the embed
+// description. If there is more than one page, reactions are added to the message
+// for switching pages
+func sendPaginatedEmbed(bot *eb.Bot, channelID string, embed *discordgo.MessageEmbed, lines []string, pageSize int) error {
+	p := &paginator{
+		channelID: channelID,
+		embed:     embed,
+		expires:   time.Now().Add(paginatorTimeout),
+	}
+
+	for i := 0; i < len(lines); i += pageSize {
+		end := i + pageSize
+
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		p.pages = append(p.pages, strings.Join(lines[i:end], "\n"))
+	}
+
+	if len(p.pages) == 0 {
+		p.pages = []string{"Nothing to show."}
+	}
+
+	msg, err := bot.Session.ChannelMessageSendEmbed(channelID, p.render())
+
+	if err != nil || len(p.pages) == 1 {
+		return err
+	}
+
+	paginators.Lock()
+
+	// Clean up any paginators which can no longer be used
+	for id, other := range paginators.m {
+		if time.Now().After(other.expires) {
+			delete(paginators.m, id)
+		}
+	}
+
+	paginators.m[msg.ID] = p
+	paginators.Unlock()
+
+	bot.Session.MessageReactionAdd(channelID, msg.ID, emojiPrevPage)
+	bot.Session.MessageReactionAdd(channelID, msg.ID, emojiNextPage)
+
+	return nil
+}
+
+// render returns a copy of the embed showing the current page
+func (p *paginator) render() *discordgo.MessageEmbed {
+	embed := *p.embed
+	embed.Description = p.pages[p.page]
+
+	if len(p.pages) > 1 {
+		embed.Footer = &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d of %d", p.page+1, len(p.pages)),
+		}
+	}
+
+	return &embed
+}
+
+// messageReactionAdd switches the page of a paginated message when someone reacts
+// to it with the previous/next emoji
+func messageReactionAdd(bot *eb.Bot, r *discordgo.MessageReactionAdd) {
+	if r.UserID == bot.Session.State.User.ID {
+		return
+	}
+
+	paginators.Lock()
+	defer paginators.Unlock()
+
+	p, exists := paginators.m[r.MessageID]
+
+	if !exists {
+		return
+	} else if time.Now().After(p.expires) {
+		delete(paginators.m, r.MessageID)
+		return
+	}
+
+	switch r.Emoji.Name {
+	case emojiPrevPage:
+		if p.page == 0 {
+			return
+		}
+
+		p.page--
+	case emojiNextPage:
+		if p.page == len(p.pages)-1 {
+			return
+		}
+
+		p.page++
+	default:
+		return
+	}
+
+	bot.Session.ChannelMessageEditEmbed(p.channelID, r.MessageID, p.render())
+
+	// Remove the reaction so the user can click it again. This will fail if the bot
+	// doesn't have the Manage Messages permission, which is fine
+	bot.Session.MessageReactionRemove(p.channelID, r.MessageID, r.Emoji.Name, r.UserID)
+}
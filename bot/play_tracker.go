@@ -53,17 +53,10 @@ func TrackAllRecentPlays(bot *eb.Bot, minAcc float64) {
 		latestUser.Chordjack = util.RoundToPrecision(latestUser.Chordjack, 2)
 		latestUser.Technical = util.RoundToPrecision(latestUser.Technical, 2)
 
-		diffMSD := etterna.MSD{
-			Overall:    latestUser.Overall - v.User.MSDOverall,
-			Stream:     latestUser.Stream - v.User.MSDStream,
-			Jumpstream: latestUser.Jumpstream - v.User.MSDJumpstream,
-			Handstream: latestUser.Handstream - v.User.MSDHandstream,
-			Stamina:    latestUser.Stamina - v.User.MSDStamina,
-			JackSpeed:  latestUser.JackSpeed - v.User.MSDJackSpeed,
-			Chordjack:  latestUser.Chordjack - v.User.MSDChordjack,
-			Technical:  latestUser.Technical - v.User.MSDTechnical,
-		}
+		oldMSD := v.User.MSD()
+		oldRank := v.User.Rank()
 
+		v.User.CountryCode = latestUser.CountryCode
 		v.User.MSDOverall = latestUser.Overall
 		v.User.MSDStream = latestUser.Stream
 		v.User.MSDJumpstream = latestUser.Jumpstream
@@ -85,47 +78,29 @@ func TrackAllRecentPlays(bot *eb.Bot, minAcc float64) {
 		v.User.LastRecentScoreDate = &s.Date
 
 		bot.Users.Save(&v.User)
+		syncRoles(bot, &v.User)
+		syncNicknames(bot, &v.User)
+		notifyRivals(bot, &v.User, oldMSD, latestUser.MSD, s)
 
-		gains := ""
-
-		if diffMSD.Overall >= 0.01 {
-			gains += fmt.Sprintf("➤ **Overall:** %.2f (+%.2f)\n", latestUser.Overall, diffMSD.Overall)
-		}
-
-		if diffMSD.Stream >= 0.01 {
-			gains += fmt.Sprintf("➤ **Stream:** %.2f (+%.2f)\n", latestUser.Stream, diffMSD.Stream)
+		if err := saveScore(bot, s, &v.User, true); err != nil {
+			fmt.Println("Failed to save score", s.Key, err)
 		}
 
-		if diffMSD.Jumpstream >= 0.01 {
-			gains += fmt.Sprintf("➤ **Jumpstream:** %.2f (+%.2f)\n", latestUser.Jumpstream, diffMSD.Jumpstream)
-		}
-
-		if diffMSD.Handstream >= 0.01 {
-			gains += fmt.Sprintf("➤ **Handstream:** %.2f (+%.2f)\n", latestUser.Handstream, diffMSD.Handstream)
-		}
+		gains := getRatingGains(oldMSD, latestUser.MSD, oldRank, latestUser.Rank)
+		goals := checkGoals(bot, &v.User, s)
+		unlocked := checkAchievements(bot, &v.User, s)
 
-		if diffMSD.Stamina >= 0.01 {
-			gains += fmt.Sprintf("➤ **Stamina:** %.2f (+%.2f)\n", latestUser.Stamina, diffMSD.Stamina)
-		}
-
-		if diffMSD.JackSpeed >= 0.01 {
-			gains += fmt.Sprintf("➤ **JackSpeed:** %.2f (+%.2f)\n", latestUser.JackSpeed, diffMSD.JackSpeed)
-		}
-
-		if diffMSD.Chordjack >= 0.01 {
-			gains += fmt.Sprintf("➤ **Chordjack:** %.2f (+%.2f)\n", latestUser.Chordjack, diffMSD.Chordjack)
-		}
-
-		if diffMSD.Technical >= 0.01 {
-			gains += fmt.Sprintf("➤ **Technical:** %.2f (+%.2f)\n", latestUser.Technical, diffMSD.Technical)
-		}
+		for _, server := range v.Servers {
+			milestones := getRankMilestones(oldRank, latestUser.Rank, server.RankMilestones)
+			entered := enterChallenges(bot, server.ServerID, &v.User, s)
 
-		// Only display the song if the player got above a certain acc or if they gained pp
-		if gains == "" && s.Accuracy < minAcc {
-			continue
-		}
+			// Only display the song if the player got above a certain acc, gained pp,
+			// reached a rank milestone, completed a goal, unlocked an achievement or
+			// entered a challenge
+			if gains == "" && milestones == "" && goals == "" && unlocked == "" && entered == "" && s.Accuracy < minAcc {
+				continue
+			}
 
-		for _, server := range v.Servers {
 			embed, err := getPlaySummaryAsDiscordEmbed(bot, s, &v.User)
 
 			if err != nil {
@@ -136,11 +111,34 @@ func TrackAllRecentPlays(bot *eb.Bot, minAcc float64) {
 				embed.Description += "\n\n" + gains
 			}
 
-			bot.Session.ChannelMessageSendEmbed(server.ScoreChannelID.String, embed)
+			if milestones != "" {
+				embed.Description += "\n\n" + milestones
+			}
+
+			if goals != "" {
+				embed.Description += "\n\n" + goals
+			}
+
+			if unlocked != "" {
+				embed.Description += "\n\n" + unlocked
+			}
+
+			if entered != "" {
+				embed.Description += "\n\n" + entered
+			}
+
+			if err := sendScoreEmbed(bot, server.ScoreChannelID.String, embed, s); err != nil {
+				fmt.Println("Failed to send recent play", s.Key, err)
+			}
 
+			// Channels without a song of their own fall back to the last recent play
 			server.LastSongID.Int64 = int64(s.Song.ID)
 			server.LastSongID.Valid = true
 
+			if err := setChannelSong(bot, server.ServerID, server.ScoreChannelID.String, s.Song.ID); err != nil {
+				fmt.Println("Failed to set the current song", err)
+			}
+
 			serversToUpdate[server.ID] = server
 		}
 	}
@@ -150,3 +148,58 @@ func TrackAllRecentPlays(bot *eb.Bot, minAcc float64) {
 		bot.Servers.Save(&s)
 	}
 }
+
+// getRatingGains returns a summary of each skillset the user gained rating in, along
+// with how their rank changed in that skillset
+func getRatingGains(oldMSD, newMSD etterna.MSD, oldRank, newRank etterna.Rank) string {
+	gains := ""
+
+	for _, ss := range etterna.Skillsets {
+		diff := newMSD.Get(ss) - oldMSD.Get(ss)
+
+		if diff < 0.01 {
+			continue
+		}
+
+		gains += fmt.Sprintf("➤ **%s:** %.2f (+%.2f)", ss, newMSD.Get(ss), diff)
+
+		// A rank of 0 means we haven't looked up the user's rank before
+		if oldRank.Get(ss) > 0 && oldRank.Get(ss) != newRank.Get(ss) {
+			gains += fmt.Sprintf(" #%d → #%d", oldRank.Get(ss), newRank.Get(ss))
+		}
+
+		gains += "\n"
+	}
+
+	return gains
+}
+
+// getRankMilestones returns a callout for each skillset where the user moved into
+// one of the given global rank milestones (e.g. the top 1000). Only the highest
+// milestone reached is shown for each skillset
+func getRankMilestones(oldRank, newRank etterna.Rank, milestones []int64) string {
+	callouts := ""
+
+	for _, ss := range etterna.Skillsets {
+		before := int64(oldRank.Get(ss))
+		after := int64(newRank.Get(ss))
+
+		if before == 0 || after == 0 || after >= before {
+			continue
+		}
+
+		var reached int64
+
+		for _, m := range milestones {
+			if after <= m && before > m && (reached == 0 || m < reached) {
+				reached = m
+			}
+		}
+
+		if reached > 0 {
+			callouts += fmt.Sprintf("🎉 **Entered the global top %d in %s!**\n", reached, ss)
+		}
+	}
+
+	return callouts
+}
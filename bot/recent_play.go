@@ -22,6 +22,15 @@ const (
 	emoteLULW = "<:LULW:458394552886099972>"
 )
 
+var gradeEmotes = map[etterna.Grade]string{
+	etterna.GradeAAAA: emoteAAAA,
+	etterna.GradeAAA:  emoteAAA,
+	etterna.GradeAA:   emoteAA,
+	etterna.GradeA:    emoteA,
+	etterna.GradeB:    emoteB,
+	etterna.GradeC:    emoteC,
+}
+
 // getRecentPlay looks up the most recent, valid play for a user.
 func getRecentPlay(bot *eb.Bot, etternaID int) (*etterna.Score, error) {
 	scores, err := bot.API.GetScores(etternaID, "", recentPlayLookupCount, 0, etterna.SortDate, false)
@@ -48,6 +57,7 @@ func getRecentPlay(bot *eb.Bot, etternaID int) (*etterna.Score, error) {
 	s.MinesHit = details.MinesHit
 	s.Mods = details.Mods
 	s.Date = details.Date
+	s.Valid = details.Valid
 
 	return &s, nil
 }
@@ -64,13 +74,7 @@ func getPlaySummaryAsDiscordEmbed(bot *eb.Bot, score *etterna.Score, user *model
 	score.Song.Name = song.Name
 	score.Song.Artist = song.Artist
 	score.Song.BackgroundURL = song.BackgroundURL
-	rateStr := fmt.Sprintf("%.2f", score.Rate)
-	length := len(rateStr)
-
-	// Remove a trailing zero if it exists (0.80 -> 0.8, 1.00 -> 1.0)
-	if rateStr[length-1] == '0' {
-		rateStr = rateStr[:length-1]
-	}
+	rateStr := formatRate(score.Rate)
 
 	var accStr string
 
@@ -80,21 +84,7 @@ func getPlaySummaryAsDiscordEmbed(bot *eb.Bot, score *etterna.Score, user *model
 		accStr = fmt.Sprintf("%.2f%%", score.Accuracy)
 	}
 
-	var gradeEmote string
-
-	if score.Accuracy >= 99.955 {
-		gradeEmote = emoteAAAA
-	} else if score.Accuracy >= 99.70 {
-		gradeEmote = emoteAAA
-	} else if score.Accuracy >= 93.00 {
-		gradeEmote = emoteAA
-	} else if score.Accuracy >= 80.00 {
-		gradeEmote = emoteA
-	} else if score.Accuracy >= 70.00 {
-		gradeEmote = emoteB
-	} else if score.Accuracy >= 60.00 {
-		gradeEmote = emoteC
-	}
+	gradeEmote := gradeEmotes[etterna.GetGrade(score.Accuracy)]
 
 	scoreURL := fmt.Sprintf(bot.API.BaseURL()+"/score/view/%s%d", score.Key, user.EtternaID)
 	description := fmt.Sprintf(
@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/etterna"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	recommendCount  = 10  // Number of charts to recommend
+	recommendWindow = 1.0 // How far above the target rating a chart can be
+	maxRecommendGap = 10  // Max difficulty offset
+)
+
+// CmdRecommend suggests charts the user hasn't played or AAA'd yet that are rated just
+// above their rating in a skillset. Ratings are estimated from other players' scores
+func CmdRecommend(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	usage := "Usage: recommend [skillset] [difficulty offset], e.g. `recommend stream +0.5`"
+	skillset := etterna.SkillsetOverall
+	offset := 0.0
+
+	for _, arg := range args[1:] {
+		if ss, ok := etterna.ParseSkillset(arg); ok {
+			skillset = ss
+		} else if n, err := strconv.ParseFloat(arg, 64); err == nil && math.Abs(n) <= maxRecommendGap {
+			offset = n
+		} else {
+			bot.Session.ChannelMessageSend(m.ChannelID, usage)
+			return
+		}
+	}
+
+	user, err := bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if user == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You are not registered with an Etterna user. "+
+			"Please register using the `setuser` command first.")
+		return
+	}
+
+	// Make sure we know which charts the user has already played
+	if ready, err := prepareScores(bot, user); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Failed to look up your scores (%s)", err.Error()))
+		return
+	} else if !ready {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Looking up all of your scores for the first time. "+
+			"This can take a few minutes, try again later.")
+		return
+	}
+
+	target := user.MSD().Get(skillset) + offset
+	candidates, err := bot.Scores.GetChartRatings(skillset, etterna.GradeA.MinAccuracy(), target, target+recommendWindow, user.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	played, err := bot.Scores.GetBestPerSongRate(user.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	best := make(map[songRate]*model.Score)
+
+	for _, s := range played {
+		best[songRate{s.SongID, s.Rate}] = s
+	}
+
+	recommended := recommendCharts(candidates, best, target)
+	description := ""
+
+	for i, c := range recommended {
+		name := fmt.Sprintf("song #%d", c.SongID)
+
+		if song, err := bot.Songs.Get(c.SongID); err == nil && song != nil {
+			name = song.Name
+		}
+
+		description += fmt.Sprintf("**%d.** [%s](%s/song/view/%d) %sx — **%.2f**",
+			i+1, name, bot.API.BaseURL(), c.SongID, formatRate(c.Rate), c.Rating)
+
+		if s, ok := best[songRate{c.SongID, c.Rate}]; ok {
+			description += fmt.Sprintf(" (your best: %.2f%%)", s.Accuracy)
+		}
+
+		description += "\n"
+	}
+
+	if description == "" {
+		description = "I couldn't find any charts around that difficulty. Try a different offset, " +
+			"or check back once more players in this server have played harder charts."
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Color:       embedColor,
+		Description: description,
+		Author: &discordgo.MessageEmbedAuthor{
+			IconURL: bot.API.BaseURL() + "/avatars/" + user.Avatar,
+			Name:    fmt.Sprintf("%s charts for %s (%.2f – %.2f)", skillset, user.Username, target, target+recommendWindow),
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Ratings are estimated from other players' scores",
+		},
+	}
+
+	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+}
+
+// recommendCharts picks the charts closest to the target rating that the user hasn't
+// played, or has played but hasn't AAA'd. Each song is only recommended at one rate,
+// whichever is closest to the target
+func recommendCharts(candidates []*model.ChartRating, best map[songRate]*model.Score, target float64) []*model.ChartRating {
+	var recommended []*model.ChartRating
+	songs := make(map[int]bool)
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return math.Abs(candidates[i].Rating-target) < math.Abs(candidates[j].Rating-target)
+	})
+
+	for _, c := range candidates {
+		if songs[c.SongID] {
+			continue
+		} else if s, ok := best[songRate{c.SongID, c.Rate}]; ok && s.Accuracy >= etterna.GradeAAA.MinAccuracy() {
+			continue
+		}
+
+		songs[c.SongID] = true
+		recommended = append(recommended, c)
+
+		if len(recommended) == recommendCount {
+			break
+		}
+	}
+
+	return recommended
+}
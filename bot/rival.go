@@ -0,0 +1,223 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/etterna"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	maxRivals = 10 // Max number of rivals a user can have
+)
+
+// CmdRival adds, removes or lists the rivals of the registered user. Users are sent
+// a DM when one of their rivals overtakes them
+func CmdRival(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	usage := "Usage: rival [list | add <username> | remove <username>]"
+
+	user, err := bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if user == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You are not registered with an Etterna user. "+
+			"Please register using the `setuser` command first.")
+		return
+	}
+
+	if len(args) == 1 || strings.ToLower(args[1]) == "list" {
+		listRivals(bot, m, user)
+		return
+	} else if len(args) != 3 {
+		bot.Session.ChannelMessageSend(m.ChannelID, usage)
+		return
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "add":
+		addRival(bot, m, user, args[2])
+	case "remove":
+		removeRival(bot, m, user, args[2])
+	default:
+		bot.Session.ChannelMessageSend(m.ChannelID, usage)
+	}
+}
+
+func listRivals(bot *eb.Bot, m *discordgo.MessageCreate, user *model.EtternaUser) {
+	rivals, err := bot.Rivals.GetRivals(user.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if len(rivals) == 0 {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You don't have any rivals yet. Add one with `rival add <username>`.")
+		return
+	}
+
+	var description string
+
+	for _, r := range rivals {
+		description += fmt.Sprintf("[%s](%s/user/%s) — %.2f (%+.2f)\n",
+			r.Username, bot.API.BaseURL(), r.Username, r.MSDOverall, r.MSDOverall-user.MSDOverall)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Color:       embedColor,
+		Description: description,
+		Author: &discordgo.MessageEmbedAuthor{
+			IconURL: bot.API.BaseURL() + "/avatars/" + user.Avatar,
+			Name:    user.Username + "'s rivals",
+		},
+	}
+
+	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+}
+
+func addRival(bot *eb.Bot, m *discordgo.MessageCreate, user *model.EtternaUser, username string) {
+	rival, err := getUserOrCreate(bot, username, false)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if rival.ID == user.ID {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You can't be your own rival.")
+		return
+	}
+
+	rivals, err := bot.Rivals.GetRivals(user.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if len(rivals) >= maxRivals {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("You can only have %d rivals. Remove one first.", maxRivals))
+		return
+	}
+
+	ok, err := bot.Rivals.Add(user.ID, rival.ID, m.Author.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if !ok {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("%s is already your rival.", rival.Username))
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID,
+		fmt.Sprintf("%s is now your rival. I'll DM you when they overtake you.", rival.Username))
+}
+
+func removeRival(bot *eb.Bot, m *discordgo.MessageCreate, user *model.EtternaUser, username string) {
+	rival, err := bot.Users.GetUsername(username)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	ok := false
+
+	if rival != nil {
+		if ok, err = bot.Rivals.Remove(user.ID, rival.ID); err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		}
+	}
+
+	if !ok {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("%s is not your rival.", username))
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("%s is no longer your rival.", rival.Username))
+}
+
+// notifyRivals sends a DM to everyone who has the user as a rival if the play made the
+// user overtake them in a skillset, or beat their best score on the song at the same
+// rate. This needs to be called before the play is added to the score history so the
+// user's previous best on the song can be looked up
+func notifyRivals(bot *eb.Bot, user *model.EtternaUser, oldMSD, newMSD etterna.MSD, score *etterna.Score) {
+	followers, err := bot.Rivals.GetFollowers(user.ID)
+
+	if err != nil {
+		fmt.Println("Failed to look up rival followers", user.Username, err)
+		return
+	} else if len(followers) == 0 {
+		return
+	}
+
+	song, err := getSongOrCreate(bot, score.Song.ID)
+
+	if err != nil {
+		fmt.Println("Failed to look up song for rivals", score.Song.ID, err)
+		return
+	}
+
+	prevBest, err := bot.Scores.GetBestOnSong(user.ID, song.EtternaID, score.Rate)
+
+	if err != nil {
+		fmt.Println("Failed to look up previous best for rivals", user.Username, err)
+		return
+	}
+
+	for _, f := range followers {
+		description := getOvertakes(user.Username, oldMSD, newMSD, f.MSD())
+		best, err := getBestScoreOnSong(bot, &f.EtternaUser, song, score.Rate)
+
+		if err != nil {
+			fmt.Println("Failed to look up best score for rival", f.Username, err)
+		} else if best != nil && score.Accuracy > best.Accuracy &&
+			(prevBest == nil || prevBest.ScoreKey == score.Key || prevBest.Accuracy <= best.Accuracy) {
+			description += fmt.Sprintf("➤ Beat your best on **%s** (%sx): %.2f%% vs. your %.2f%%\n",
+				song.Name, formatRate(score.Rate), score.Accuracy, best.Accuracy)
+		}
+
+		if description == "" {
+			continue
+		}
+
+		channel, err := bot.Session.UserChannelCreate(f.DiscordUserID)
+
+		if err != nil {
+			fmt.Println("Failed to open DM for rival", f.DiscordUserID, err)
+			continue
+		}
+
+		embed := &discordgo.MessageEmbed{
+			Color:       embedColor,
+			Description: description,
+			Author: &discordgo.MessageEmbedAuthor{
+				IconURL: bot.API.BaseURL() + "/avatars/" + user.Avatar,
+				Name:    "Your rival " + user.Username + " overtook you",
+				URL:     bot.API.BaseURL() + "/user/" + user.Username,
+			},
+		}
+
+		bot.Session.ChannelMessageSendEmbed(channel.ID, embed)
+	}
+}
+
+// getOvertakes lists each skillset where the user's rating went from at or below the
+// follower's rating to above it
+func getOvertakes(username string, oldMSD, newMSD, followerMSD etterna.MSD) string {
+	overtakes := ""
+
+	for _, ss := range etterna.Skillsets {
+		theirs := followerMSD.Get(ss)
+
+		// The follower's rating is 0 if we haven't looked it up yet
+		if theirs == 0 || oldMSD.Get(ss) > theirs || newMSD.Get(ss) <= theirs {
+			continue
+		}
+
+		overtakes += fmt.Sprintf("➤ **%s:** %s is now %.2f, ahead of your %.2f\n", ss, username, newMSD.Get(ss), theirs)
+	}
+
+	return overtakes
+}
@@ -0,0 +1,261 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	reRoleMention = regexp.MustCompile(`^<@&(\d+)>$`)
+)
+
+// CmdRoles manages the roles that players in the server are given for reaching a
+// rating or rank. Roles are updated whenever a player's ratings are looked up
+func CmdRoles(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	usage := "Usage: roles [list | add <role> <skillset> <rating> | add <role> top <rank> [in <skillset>] | remove <role> | sync]"
+
+	if len(args) == 1 || strings.ToLower(args[1]) == "list" {
+		listRoleRewards(bot, m)
+		return
+	}
+
+	if !isServerAdmin(bot, m) {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You need the Manage Server permission to do that.")
+		return
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "add":
+		if len(args) < 5 {
+			bot.Session.ChannelMessageSend(m.ChannelID, usage)
+			return
+		}
+
+		addRoleReward(bot, m, args[2], args[3:])
+	case "remove":
+		if len(args) != 3 {
+			bot.Session.ChannelMessageSend(m.ChannelID, usage)
+			return
+		}
+
+		removeRoleReward(bot, m, args[2])
+	case "sync":
+		syncServerRoles(bot, m)
+	default:
+		bot.Session.ChannelMessageSend(m.ChannelID, usage)
+	}
+}
+
+func listRoleRewards(bot *eb.Bot, m *discordgo.MessageCreate) {
+	rewards, err := bot.Roles.GetAll(m.GuildID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if len(rewards) == 0 {
+		bot.Session.ChannelMessageSend(m.ChannelID, "There aren't any role rewards in this server. "+
+			"Add one with `roles add <role> <skillset> <rating>` or `roles add <role> top <rank>`.")
+		return
+	}
+
+	description := ""
+
+	for _, r := range rewards {
+		description += fmt.Sprintf("<@&%s> — %s\n", r.RoleID, describeGoal(bot, roleRewardGoal(r)))
+	}
+
+	bot.Session.ChannelMessageSendEmbed(m.ChannelID, &discordgo.MessageEmbed{
+		Color:       embedColor,
+		Title:       "Role rewards",
+		Description: description,
+	})
+}
+
+func addRoleReward(bot *eb.Bot, m *discordgo.MessageCreate, roleArg string, args []string) {
+	roleID, err := findRole(bot, m.GuildID, roleArg)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if roleID == "" {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Could not find that role. Mention the role or use its name (without spaces).")
+		return
+	}
+
+	// Role rewards use the same thresholds as rating and rank goals
+	goal, songQuery, err := parseGoal(args)
+
+	if err != nil || songQuery != "" || goal.Kind == model.GoalScore {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Usage: roles add <role> <skillset> <rating> | roles add <role> top <rank> [in <skillset>]")
+		return
+	}
+
+	reward := &model.RoleReward{
+		ServerID:  m.GuildID,
+		RoleID:    roleID,
+		Kind:      goal.Kind,
+		Skillset:  goal.Skillset,
+		Threshold: goal.Target,
+	}
+
+	if err := bot.Roles.Save(reward); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Players will be given <@&%s> for %s. Use `roles sync` to update everyone now.",
+		roleID, describeGoal(bot, goal)))
+}
+
+func removeRoleReward(bot *eb.Bot, m *discordgo.MessageCreate, roleArg string) {
+	roleID, err := findRole(bot, m.GuildID, roleArg)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if roleID == "" {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Could not find that role.")
+		return
+	}
+
+	if ok, err := bot.Roles.Delete(m.GuildID, roleID); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if !ok {
+		bot.Session.ChannelMessageSend(m.ChannelID, "That role isn't a reward.")
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, "Removed the role reward. Players who already have the role will keep it.")
+}
+
+// syncServerRoles updates the roles of every player registered in the server using
+// their cached ratings and ranks
+func syncServerRoles(bot *eb.Bot, m *discordgo.MessageCreate) {
+	rewards, err := bot.Roles.GetAll(m.GuildID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if len(rewards) == 0 {
+		bot.Session.ChannelMessageSend(m.ChannelID, "There aren't any role rewards in this server.")
+		return
+	}
+
+	users, err := bot.Users.GetRegisteredUsers(m.GuildID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	synced := 0
+
+	for _, u := range users {
+		discordID, err := bot.Users.GetRegisteredDiscordUserID(m.GuildID, u.Username)
+
+		if err != nil || discordID == "" {
+			continue
+		}
+
+		if err := updateMemberRoles(bot, m.GuildID, discordID, u, rewards); err != nil {
+			fmt.Println("Failed to sync roles", m.GuildID, u.Username, err)
+			continue
+		}
+
+		synced++
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Updated the roles of %d/%d players.", synced, len(users)))
+}
+
+// syncRoles updates the user's roles in every server they're registered in. Should be
+// called whenever the user's ratings or ranks are updated
+func syncRoles(bot *eb.Bot, user *model.EtternaUser) {
+	registrations, err := bot.Users.GetRegistrations(user.Username)
+
+	if err != nil {
+		fmt.Println("Failed to look up registrations", user.Username, err)
+		return
+	}
+
+	for _, r := range registrations {
+		rewards, err := bot.Roles.GetAll(r.ServerID)
+
+		if err != nil {
+			fmt.Println("Failed to look up role rewards", r.ServerID, err)
+			continue
+		} else if len(rewards) == 0 {
+			continue
+		}
+
+		if err := updateMemberRoles(bot, r.ServerID, r.DiscordUserID, user, rewards); err != nil {
+			fmt.Println("Failed to sync roles", r.ServerID, user.Username, err)
+		}
+	}
+}
+
+// updateMemberRoles adds the reward roles the user qualifies for, and removes the ones
+// they no longer qualify for
+func updateMemberRoles(bot *eb.Bot, serverID, discordID string, user *model.EtternaUser, rewards []*model.RoleReward) error {
+	member, err := bot.Session.GuildMember(serverID, discordID)
+
+	if err != nil {
+		return err
+	}
+
+	has := make(map[string]bool)
+
+	for _, id := range member.Roles {
+		has[id] = true
+	}
+
+	for _, r := range rewards {
+		qualifies := isGoalComplete(roleRewardGoal(r), user.MSD(), user.Rank(), nil)
+
+		if qualifies && !has[r.RoleID] {
+			err = bot.Session.GuildMemberRoleAdd(serverID, discordID, r.RoleID)
+		} else if !qualifies && has[r.RoleID] {
+			err = bot.Session.GuildMemberRoleRemove(serverID, discordID, r.RoleID)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// roleRewardGoal returns the reward's threshold as a goal so it can be checked and
+// described the same way
+func roleRewardGoal(r *model.RoleReward) *model.Goal {
+	return &model.Goal{Kind: r.Kind, Skillset: r.Skillset, Target: r.Threshold}
+}
+
+// findRole looks up a role in the server by a mention, ID or name. Returns an empty
+// string if the role doesn't exist
+func findRole(bot *eb.Bot, serverID, arg string) (string, error) {
+	if match := reRoleMention.FindStringSubmatch(arg); match != nil {
+		arg = match[1]
+	}
+
+	roles, err := bot.Session.GuildRoles(serverID)
+
+	if err != nil {
+		return "", err
+	}
+
+	for _, r := range roles {
+		if r.ID == arg || strings.EqualFold(r.Name, arg) {
+			return r.ID, nil
+		}
+	}
+
+	return "", nil
+}
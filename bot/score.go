@@ -0,0 +1,278 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/etterna"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	scoreSyncPage = 100   // Number of scores to look up at a time when syncing
+	maxScoreSync  = 10000 // Max number of scores to look through when syncing
+)
+
+// Locks for users whose scores are being synced, keyed by user ID
+var syncingUsers = struct {
+	sync.Mutex
+	m map[uint]*sync.Mutex
+}{m: make(map[uint]*sync.Mutex)}
+
+// Users whose first score sync is running in the background
+var initialSyncs = struct {
+	sync.Mutex
+	m map[uint]bool
+}{m: make(map[uint]bool)}
+
+// saveScore adds a score played by the user to the score history. The score should
+// already have its details (date, mods, etc.) filled in. Tracked should be true if the
+// score was found by the recent plays tracker
+func saveScore(bot *eb.Bot, score *etterna.Score, user *model.EtternaUser, tracked bool) error {
+	// Make sure the song is cached since scores reference it
+	if _, err := getSongOrCreate(bot, score.Song.ID); err != nil {
+		return err
+	}
+
+	s := newScoreModel(score, user)
+	s.Tracked = tracked
+
+	return bot.Scores.Save(s)
+}
+
+// saveScoreSummary adds a score from the user's score list to the score history. If
+// the song isn't cached yet, it's cached with just the name from the score list so
+// syncing doesn't need to look up every song on EO. The rest of the song's details
+// are filled in the next time it's looked up with getSongOrCreate
+func saveScoreSummary(bot *eb.Bot, score *etterna.Score, user *model.EtternaUser) error {
+	song, err := bot.Songs.Get(score.Song.ID)
+
+	if err != nil {
+		return err
+	} else if song == nil {
+		song = &model.Song{
+			EtternaID: score.Song.ID,
+			Name:      score.Song.Name,
+		}
+
+		if err := bot.Songs.Save(song); err != nil {
+			return err
+		}
+	}
+
+	return bot.Scores.SaveSummary(newScoreModel(score, user))
+}
+
+// newScoreModel converts a score from the API to a score that can be saved
+func newScoreModel(score *etterna.Score, user *model.EtternaUser) *model.Score {
+	return &model.Score{
+		ScoreKey:      score.Key,
+		UserID:        user.ID,
+		SongID:        score.Song.ID,
+		Rate:          score.Rate,
+		Accuracy:      score.Accuracy,
+		Marvelous:     score.Marvelous,
+		Perfect:       score.Perfect,
+		Great:         score.Great,
+		Good:          score.Good,
+		Bad:           score.Bad,
+		Miss:          score.Miss,
+		MaxCombo:      score.MaxCombo,
+		MinesHit:      score.MinesHit,
+		MSDOverall:    score.Overall,
+		MSDStream:     score.Stream,
+		MSDJumpstream: score.Jumpstream,
+		MSDHandstream: score.Handstream,
+		MSDStamina:    score.Stamina,
+		MSDJackSpeed:  score.JackSpeed,
+		MSDChordjack:  score.Chordjack,
+		MSDTechnical:  score.Technical,
+		Nerfed:        score.Nerfed,
+		Mods:          score.Mods,
+		Valid:         score.Valid,
+		PlayedAt:      score.Date,
+	}
+}
+
+// syncScores adds any scores in the user's EO score list that aren't in the score
+// history yet. The first sync goes through the whole list. After that, scores are
+// looked up newest first until a page has no new scores. Scores that can't be saved
+// are skipped so they don't stop the rest of the sync. Only one sync runs for a user
+// at a time, and anyone else who wants to sync the user waits for it to finish
+func syncScores(bot *eb.Bot, user *model.EtternaUser) error {
+	syncingUsers.Lock()
+	lock, exists := syncingUsers.m[user.ID]
+
+	if !exists {
+		lock = &sync.Mutex{}
+		syncingUsers.m[user.ID] = lock
+	}
+
+	syncingUsers.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	lastSync, err := bot.Scores.GetLastSync(user.ID)
+
+	if err != nil {
+		return err
+	}
+
+	keys, err := bot.Scores.GetKeys(user.ID)
+
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+
+	for _, k := range keys {
+		known[k] = true
+	}
+
+	for start := 0; start < maxScoreSync; start += scoreSyncPage {
+		scores, err := bot.API.GetScores(user.EtternaID, "", scoreSyncPage, uint(start), etterna.SortDate, false)
+
+		if err != nil {
+			return err
+		}
+
+		newScores := 0
+
+		for i := range scores {
+			if known[scores[i].Key] {
+				continue
+			}
+
+			// The score list only has valid scores
+			scores[i].Valid = true
+			newScores++
+
+			if err := saveScoreSummary(bot, &scores[i], user); err != nil {
+				fmt.Println("Failed to save synced score", user.Username, scores[i].Key, scores[i].Song.ID, err)
+			}
+		}
+
+		if len(scores) < scoreSyncPage || (lastSync != nil && newScores == 0) {
+			break
+		}
+	}
+
+	return bot.Scores.SetLastSync(user.ID, time.Now().UTC())
+}
+
+// prepareScores makes sure the user's score history is up to date. The first sync
+// goes through the user's whole score list, which can take a while, so it's run in
+// the background and false is returned until it finishes. Later syncs only look up
+// new scores and are run right away
+func prepareScores(bot *eb.Bot, user *model.EtternaUser) (bool, error) {
+	lastSync, err := bot.Scores.GetLastSync(user.ID)
+
+	if err != nil {
+		return false, err
+	} else if lastSync != nil {
+		return true, syncScores(bot, user)
+	}
+
+	initialSyncs.Lock()
+	defer initialSyncs.Unlock()
+
+	if !initialSyncs.m[user.ID] {
+		initialSyncs.m[user.ID] = true
+
+		go func() {
+			if err := syncScores(bot, user); err != nil {
+				fmt.Println("Failed to sync scores", user.Username, err)
+			}
+
+			initialSyncs.Lock()
+			delete(initialSyncs.m, user.ID)
+			initialSyncs.Unlock()
+		}()
+	}
+
+	return false, nil
+}
+
+// sendScoreEmbed sends the summary of a score and records which score the message is
+// for, so that commands which reply to the message can use its song and rate
+func sendScoreEmbed(bot *eb.Bot, channelID string, embed *discordgo.MessageEmbed, score *etterna.Score) error {
+	msg, err := bot.Session.ChannelMessageSendEmbed(channelID, embed)
+
+	if err != nil {
+		return err
+	}
+
+	return bot.Posted.Save(&model.PostedScore{
+		MessageID: msg.ID,
+		ChannelID: channelID,
+		SongID:    score.Song.ID,
+		ScoreKey:  score.Key,
+		Rate:      score.Rate,
+	})
+}
+
+// getBestScoreOnSong looks up the user's best score (by nerfed rating) on a song. If
+// rate is non-zero, only scores at that rate are considered. Returns nil if the user
+// has no matching scores
+func getBestScoreOnSong(bot *eb.Bot, user *model.EtternaUser, song *model.Song, rate float64) (*etterna.Score, error) {
+	scores, err := bot.API.GetScores(user.EtternaID, song.Name, 100, 0, etterna.SortNerf, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, s := range scores {
+		if s.Song.ID == song.EtternaID && (rate == 0 || s.Rate == rate) {
+			return &scores[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// parseRate parses a music rate such as "1.2" or "1.2x". The rate must be between
+// 0.7 and 3.0, and in 0.05 increments
+func parseRate(s string) (float64, error) {
+	s = strings.TrimSuffix(strings.ToLower(s), "x")
+	rate, err := strconv.ParseFloat(s, 64)
+
+	if err != nil {
+		return 0, errors.New("Rate must be a number, e.g. 1.2")
+	} else if rate < 0.7 || rate > 3.0 {
+		return 0, errors.New("Rate must be between 0.7 and 3.0.")
+	}
+
+	split := strings.Split(s, ".")
+
+	// Has a decimal part
+	if len(split) == 2 {
+		// Verify the decimal part is not longer than 2 digits and that if it is 2 digits the
+		// leading number is a 0 or a 5.
+		if len(split[1]) > 2 || (len(split[1]) == 2 && split[1][1] != '0' && split[1][1] != '5') {
+			return 0, errors.New("Rate must be in 0.05 increments.")
+		}
+	}
+
+	return rate, nil
+}
+
+// formatRate formats a rate the same way etterna does (0.8, 1.0, 1.15)
+func formatRate(rate float64) string {
+	rateStr := fmt.Sprintf("%.2f", rate)
+	length := len(rateStr)
+
+	// Remove a trailing zero if it exists (0.80 -> 0.8, 1.00 -> 1.0)
+	if rateStr[length-1] == '0' {
+		rateStr = rateStr[:length-1]
+	}
+
+	return rateStr
+}
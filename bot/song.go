@@ -1,37 +1,105 @@
 package bot
 
 import (
+	"strconv"
+
 	eb "github.com/Kangaroux/etternabot"
 	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
 )
 
 // getSongOrCreate looks up a song in the database by its etterna ID, and retrieves it
-// from the API if it doesn't exist
+// from the API if it doesn't exist or only the name is cached
 func getSongOrCreate(bot *eb.Bot, id int) (*model.Song, error) {
 	song, err := bot.Songs.Get(id)
 
 	if err != nil {
 		return nil, err
-	} else if song != nil {
+	} else if song != nil && (song.Artist != "" || song.BackgroundURL != "") {
 		return song, nil
 	}
 
 	etternaSong, err := bot.API.GetSong(id)
 
 	if err != nil {
+		// Songs cached from a score sync only have a name, which is better than nothing
+		if song != nil {
+			return song, nil
+		}
+
 		return nil, err
 	}
 
-	song = &model.Song{
-		EtternaID:     etternaSong.ID,
-		Artist:        etternaSong.Artist,
-		Name:          etternaSong.Name,
-		BackgroundURL: etternaSong.BackgroundURL,
+	if song == nil {
+		song = &model.Song{}
 	}
 
+	song.EtternaID = etternaSong.ID
+	song.Artist = etternaSong.Artist
+	song.Name = etternaSong.Name
+	song.BackgroundURL = etternaSong.BackgroundURL
+
 	if err := bot.Songs.Save(song); err != nil {
 		return nil, err
 	}
 
 	return song, nil
 }
+
+// findSong looks up a song by its etterna ID, or by name from the cached songs.
+// Returns nil if no song matches
+func findSong(bot *eb.Bot, query string) (*model.Song, error) {
+	if id, err := strconv.Atoi(query); err == nil {
+		return getSongOrCreate(bot, id)
+	}
+
+	songs, err := bot.Songs.Search(query, 1)
+
+	if err != nil || len(songs) == 0 {
+		return nil, err
+	}
+
+	return songs[0], nil
+}
+
+// getTargetSong returns the ID of the song that compare and the song leaderboard use.
+// If the command is a reply to a score posted by the bot, that score's song and rate
+// are used. Otherwise it's the last song posted in the channel, or the last recent
+// play posted in the server's score channel if the channel doesn't have one. The rate
+// is 0 when the target isn't a specific score
+func getTargetSong(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate) (int, float64, bool, error) {
+	if m.MessageReference != nil && m.MessageReference.MessageID != "" {
+		posted, err := bot.Posted.Get(m.MessageReference.MessageID)
+
+		if err != nil {
+			return 0, 0, false, err
+		} else if posted != nil {
+			return posted.SongID, posted.Rate, true, nil
+		}
+	}
+
+	channel, err := bot.Channels.Get(m.ChannelID)
+
+	if err != nil {
+		return 0, 0, false, err
+	} else if channel != nil && channel.LastSongID.Valid {
+		return int(channel.LastSongID.Int64), 0, true, nil
+	} else if server.LastSongID.Valid {
+		return int(server.LastSongID.Int64), 0, true, nil
+	}
+
+	return 0, 0, false, nil
+}
+
+// setChannelSong saves the last song posted in a channel
+func setChannelSong(bot *eb.Bot, serverID, channelID string, songID int) error {
+	channel := &model.DiscordChannel{
+		ServerID:  serverID,
+		ChannelID: channelID,
+	}
+
+	channel.LastSongID.Int64 = int64(songID)
+	channel.LastSongID.Valid = true
+
+	return bot.Channels.Save(channel)
+}
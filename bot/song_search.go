@@ -0,0 +1,249 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/etterna"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/Kangaroux/etternabot/util"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	songSearchResults    = 10               // Max number of songs to show in search results
+	songSearchMinMatch   = 0.5              // Min fuzzy match score for a song to be shown
+	songSearchGoodMatch  = 0.85             // Match score where we don't bother looking on EO for more songs
+	songSearchTimeout    = 30 * time.Minute // How long search results can be picked from
+	songSearchLookupUser = 5                // Number of registered users whose scores are searched on EO
+	songSearchMaxNew     = 10               // Max number of new songs to cache from an EO search
+	songSearchCandidates = 1000             // Max number of cached songs to fuzzy match against
+)
+
+// songSearch is the results of the last song search in a channel
+type songSearch struct {
+	songs   []*model.Song
+	expires time.Time
+}
+
+// Latest song search results, keyed by channel ID
+var songSearches = struct {
+	sync.Mutex
+	m map[string]*songSearch
+}{m: make(map[string]*songSearch)}
+
+// CmdSongSearch searches the song catalog for songs matching a name or artist. The
+// results can be picked from with the pick command
+func CmdSongSearch(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	query := strings.TrimSpace(strings.Join(args[1:], " "))
+
+	if query == "" {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Usage: song <name, artist or song ID>")
+		return
+	}
+
+	bot.Session.ChannelTyping(m.ChannelID)
+	songs, err := searchSongs(bot, m.GuildID, query)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if len(songs) == 0 {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("No songs found matching '%s'.", query))
+		return
+	}
+
+	songSearches.Lock()
+	songSearches.m[m.ChannelID] = &songSearch{
+		songs:   songs,
+		expires: time.Now().Add(songSearchTimeout),
+	}
+	songSearches.Unlock()
+
+	var description string
+
+	for i, s := range songs {
+		description += fmt.Sprintf("**%d.** [%s](%s/song/view/%d) — %s\n", i+1, s.Name, bot.API.BaseURL(), s.EtternaID, s.Artist)
+	}
+
+	description += "\nUse `pick <number>` to make one of these the current song for `compare` and `songlb`."
+
+	embed := &discordgo.MessageEmbed{
+		Color:       embedColor,
+		Title:       "Song search: " + query,
+		Description: description,
+	}
+
+	bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+}
+
+// CmdPickSong sets the current song to one of the results from the last song search
+// in the channel
+func CmdPickSong(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate, args []string) {
+	if len(args) < 2 {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Usage: pick <number>")
+		return
+	}
+
+	n, err := strconv.Atoi(args[1])
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Usage: pick <number>")
+		return
+	}
+
+	songSearches.Lock()
+	search, exists := songSearches.m[m.ChannelID]
+
+	if exists && time.Now().After(search.expires) {
+		delete(songSearches.m, m.ChannelID)
+		exists = false
+	}
+
+	songSearches.Unlock()
+
+	if !exists {
+		bot.Session.ChannelMessageSend(m.ChannelID, "There are no search results to pick from. Use the `song` command to search for a song.")
+		return
+	} else if n < 1 || n > len(search.songs) {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Pick a number between 1 and %d.", len(search.songs)))
+		return
+	}
+
+	song := search.songs[n-1]
+
+	if err := setChannelSong(bot, server.ServerID, m.ChannelID, song.EtternaID); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("The current song in this channel is now '%s'.", song.Name))
+}
+
+// searchSongs finds the songs that best match the query. Songs are matched against the
+// local song catalog first. If there isn't a good match, the scores of players in the
+// server are searched on EO and any new songs are added to the catalog
+func searchSongs(bot *eb.Bot, serverID, query string) ([]*model.Song, error) {
+	if id, err := strconv.Atoi(query); err == nil {
+		song, err := getSongOrCreate(bot, id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []*model.Song{song}, nil
+	}
+
+	songs, err := bot.Songs.SearchCandidates(query, songSearchCandidates)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := rankSongs(songs, query)
+
+	if len(results) > 0 && util.FuzzyMatch(query, results[0].Name) >= songSearchGoodMatch {
+		return results, nil
+	}
+
+	known := make(map[int]bool)
+
+	for _, s := range songs {
+		known[s.EtternaID] = true
+	}
+
+	discovered, err := discoverSongs(bot, serverID, query, known)
+
+	if err != nil {
+		fmt.Println("Failed to search EO for songs", query, err)
+	}
+
+	if len(discovered) == 0 {
+		return results, nil
+	}
+
+	return rankSongs(append(songs, discovered...), query), nil
+}
+
+// discoverSongs searches the EO scores of users registered in the server for songs
+// that match the query, and caches any songs that aren't known yet
+func discoverSongs(bot *eb.Bot, serverID, query string, known map[int]bool) ([]*model.Song, error) {
+	var discovered []*model.Song
+
+	users, err := bot.Users.GetRegisteredUsers(serverID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, u := range users {
+		if i == songSearchLookupUser || len(discovered) == songSearchMaxNew {
+			break
+		}
+
+		scores, err := bot.API.GetScores(u.EtternaID, query, 25, 0, etterna.SortNerf, false)
+
+		if err != nil {
+			return discovered, err
+		}
+
+		for _, s := range scores {
+			if known[s.Song.ID] || len(discovered) == songSearchMaxNew {
+				continue
+			}
+
+			known[s.Song.ID] = true
+			song, err := getSongOrCreate(bot, s.Song.ID)
+
+			if err != nil {
+				return discovered, err
+			}
+
+			discovered = append(discovered, song)
+		}
+	}
+
+	return discovered, nil
+}
+
+// rankSongs returns the songs that match the query by name or artist, best match first
+func rankSongs(songs []*model.Song, query string) []*model.Song {
+	type match struct {
+		song  *model.Song
+		score float64
+	}
+
+	var matches []match
+
+	for _, s := range songs {
+		score := math.Max(
+			util.FuzzyMatch(query, s.Name),
+			math.Max(
+				util.FuzzyMatch(query, s.Artist)*0.9,
+				util.FuzzyMatch(query, s.Artist+" "+s.Name)*0.95,
+			),
+		)
+
+		if score >= songSearchMinMatch {
+			matches = append(matches, match{song: s, score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	var result []*model.Song
+
+	for i := 0; i < len(matches) && i < songSearchResults; i++ {
+		result = append(result, matches[i].song)
+	}
+
+	return result
+}
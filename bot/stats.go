@@ -0,0 +1,224 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/chart"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	sessionGap        = time.Hour // Plays further apart than this are in different sessions
+	favoriteRateCount = 3         // Number of favorite rates to show
+	heatmapWeeks      = 52        // Number of weeks shown in the activity heatmap
+)
+
+// activity is a summary of when and how much a user plays
+type activity struct {
+	plays         int
+	days          int // Number of days with at least one play
+	currentStreak int // Consecutive days played, up to today or yesterday
+	longestStreak int
+	sessions      int
+	longest       time.Duration // Longest session
+	total         time.Duration // Time spent in sessions
+	notes         int           // Notes hit (anything but a miss)
+	first         time.Time
+	last          time.Time
+	perDay        map[time.Time]int // Number of plays on each day (UTC)
+	rates         map[float64]int   // Number of plays at each rate
+}
+
+// CmdStats shows how much a user has been playing: streaks, sessions, notes hit and
+// favorite rates. Add -heatmap to include a calendar of their activity
+func CmdStats(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	var err error
+	var user *model.EtternaUser
+
+	args, withHeatmap := popFlag(args, "-heatmap")
+
+	if len(args) == 1 {
+		user, err = bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID)
+	} else {
+		user, err = getUserOrCreate(bot, args[1], false)
+	}
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if user == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You are not registered with an Etterna user. "+
+			"Please register using the `setuser` command, or specify a user: stats <username>")
+		return
+	}
+
+	scores, err := bot.Scores.GetTrackedHistory(user.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if len(scores) == 0 {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("%s doesn't have any tracked plays yet.", user.Username))
+		return
+	}
+
+	now := time.Now().UTC()
+	a := getActivity(scores, now)
+
+	// Weeks are counted from the first play, and there's always at least one
+	weeks := math.Max(1, now.Sub(a.first).Hours()/(24*7))
+	days := math.Max(1, now.Sub(a.first).Hours()/24)
+
+	description := fmt.Sprintf("➤ **Plays:** %d over %d days (since %s)\n", a.plays, a.days, a.first.Format("Jan 2, 2006"))
+	description += fmt.Sprintf("➤ **Plays per day:** %.1f (%.1f per week)\n", float64(a.plays)/days, float64(a.plays)/weeks)
+	description += fmt.Sprintf("➤ **Notes hit:** %d\n", a.notes)
+	description += fmt.Sprintf("➤ **Streak:** %d days (longest: %d days)\n", a.currentStreak, a.longestStreak)
+	description += fmt.Sprintf("➤ **Sessions:** %d (average: %s, %.1f plays; longest: %s)\n",
+		a.sessions, formatDuration(a.total/time.Duration(a.sessions)), float64(a.plays)/float64(a.sessions), formatDuration(a.longest))
+	description += fmt.Sprintf("➤ **Favorite rates:** %s\n", formatFavoriteRates(a.rates, a.plays))
+	description += fmt.Sprintf("➤ **Last played:** %s", a.last.Format("Jan 2, 2006"))
+
+	embed := &discordgo.MessageEmbed{
+		Color:       embedColor,
+		Description: description,
+		Author: &discordgo.MessageEmbedAuthor{
+			IconURL: bot.API.BaseURL() + "/avatars/" + user.Avatar,
+			Name:    user.Username + "'s stats",
+			URL:     bot.API.BaseURL() + "/user/" + user.Username,
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "From tracked plays",
+		},
+	}
+
+	if !withHeatmap {
+		bot.Session.ChannelMessageSendEmbed(m.ChannelID, embed)
+		return
+	}
+
+	img := chart.Heatmap(user.Username+"'s activity", a.perDay, now, heatmapWeeks)
+
+	if err := sendEmbedWithImage(bot, m.ChannelID, embed, "heatmap.png", img); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+	}
+}
+
+// getActivity summarizes the scores, which must be sorted by when they were played
+func getActivity(scores []*model.Score, now time.Time) *activity {
+	a := &activity{
+		plays:  len(scores),
+		first:  scores[0].PlayedAt,
+		last:   scores[len(scores)-1].PlayedAt,
+		perDay: make(map[time.Time]int),
+		rates:  make(map[float64]int),
+	}
+
+	var sessionStart time.Time
+
+	for i, s := range scores {
+		a.notes += s.Marvelous + s.Perfect + s.Great + s.Good + s.Bad
+		a.perDay[startOfDay(s.PlayedAt)]++
+		a.rates[s.Rate]++
+
+		if i == 0 || s.PlayedAt.Sub(scores[i-1].PlayedAt) > sessionGap {
+			a.sessions++
+			sessionStart = s.PlayedAt
+		} else {
+			a.total += s.PlayedAt.Sub(scores[i-1].PlayedAt)
+		}
+
+		if length := s.PlayedAt.Sub(sessionStart); length > a.longest {
+			a.longest = length
+		}
+	}
+
+	a.days = len(a.perDay)
+
+	// Walk forward through each day that was played, counting runs of consecutive days
+	var days []time.Time
+
+	for day := range a.perDay {
+		days = append(days, day)
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+
+	streak := 0
+
+	for i, day := range days {
+		if i > 0 && days[i-1].AddDate(0, 0, 1).Equal(day) {
+			streak++
+		} else {
+			streak = 1
+		}
+
+		if streak > a.longestStreak {
+			a.longestStreak = streak
+		}
+	}
+
+	// The streak is still going if the user played today or yesterday
+	today := startOfDay(now)
+	lastDay := days[len(days)-1]
+
+	if lastDay.Equal(today) || lastDay.Equal(today.AddDate(0, 0, -1)) {
+		a.currentStreak = streak
+	}
+
+	return a
+}
+
+// startOfDay returns midnight (UTC) of the day of the given time
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// formatFavoriteRates lists the most played rates with the percent of plays at each
+func formatFavoriteRates(rates map[float64]int, plays int) string {
+	var sorted []float64
+
+	for r := range rates {
+		sorted = append(sorted, r)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if rates[sorted[i]] == rates[sorted[j]] {
+			return sorted[i] < sorted[j]
+		}
+
+		return rates[sorted[i]] > rates[sorted[j]]
+	})
+
+	favorites := ""
+
+	for i, r := range sorted {
+		if i == favoriteRateCount {
+			break
+		} else if i > 0 {
+			favorites += ", "
+		}
+
+		favorites += fmt.Sprintf("%sx (%.0f%%)", formatRate(r), float64(rates[r])/float64(plays)*100)
+	}
+
+	return favorites
+}
+
+// formatDuration formats a duration as hours and minutes, e.g. "1h 25m"
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,190 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/etterna"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	defaultTopCount = 25  // Number of plays the top command shows by default
+	maxTopCount     = 100 // Max number of plays the top command can show
+	topPageLookup   = 100 // Number of scores to look up at a time
+	maxTopLookup    = 500 // Max number of scores to look through when filtering
+)
+
+var (
+	reScoreFilter = regexp.MustCompile(`^(rate|acc)(>=|<=|=|>|<)(\d*\.?\d+)[x%]?$`)
+)
+
+// scoreFilter is a condition that a score must meet, e.g. "acc>=99"
+type scoreFilter struct {
+	field string
+	op    string
+	value float64
+}
+
+// parseScoreFilter parses a filter in the form <rate|acc><op><value>. Returns nil if
+// the string isn't a filter
+func parseScoreFilter(s string) *scoreFilter {
+	match := reScoreFilter.FindStringSubmatch(strings.ToLower(s))
+
+	if match == nil {
+		return nil
+	}
+
+	value, _ := strconv.ParseFloat(match[3], 64)
+
+	return &scoreFilter{field: match[1], op: match[2], value: value}
+}
+
+// matches checks if the score meets the filter's condition
+func (f *scoreFilter) matches(s *etterna.Score) bool {
+	v := s.Accuracy
+
+	if f.field == "rate" {
+		v = s.Rate
+	}
+
+	switch f.op {
+	case ">=":
+		return v >= f.value
+	case "<=":
+		return v <= f.value
+	case ">":
+		return v > f.value
+	case "<":
+		return v < f.value
+	default:
+		return v == f.value
+	}
+}
+
+// CmdTopPlays lists a user's best plays, sorted by nerfed rating or by the rating
+// of a specific skillset. Plays can be filtered by rate or accuracy
+func CmdTopPlays(bot *eb.Bot, m *discordgo.MessageCreate, args []string) {
+	var err error
+	var user *model.EtternaUser
+	var username string
+	var filters []*scoreFilter
+	var skillset *etterna.Skillset
+
+	count := defaultTopCount
+	usage := "Usage: top [username] [skillset] [count] [rate>=1.2] [acc>=99]"
+
+	for _, arg := range args[1:] {
+		if arg == "" {
+			continue
+		} else if f := parseScoreFilter(arg); f != nil {
+			filters = append(filters, f)
+		} else if ss, ok := etterna.ParseSkillset(arg); ok {
+			skillset = &ss
+		} else if n, err := strconv.Atoi(arg); err == nil {
+			if n < 1 || n > maxTopCount {
+				bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Count must be between 1 and %d.", maxTopCount))
+				return
+			}
+
+			count = n
+		} else if username == "" {
+			username = arg
+		} else {
+			bot.Session.ChannelMessageSend(m.ChannelID, usage)
+			return
+		}
+	}
+
+	if username == "" {
+		user, err = bot.Users.GetRegisteredUser(m.GuildID, m.Author.ID)
+	} else {
+		user, err = getUserOrCreate(bot, username, false)
+	}
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if user == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You are not registered with an Etterna user. "+
+			"Please register using the `setuser` command, or specify a user: top <username>")
+		return
+	}
+
+	bot.Session.ChannelTyping(m.ChannelID)
+
+	sortColumn := etterna.SortNerf
+	sortName := "Nerfed"
+
+	if skillset != nil {
+		sortColumn = skillset.SortColumn()
+		sortName = skillset.String()
+	}
+
+	var plays []etterna.Score
+
+	// Keep looking up scores until there are enough that match the filters
+	for start := 0; len(plays) < count && start < maxTopLookup; start += topPageLookup {
+		scores, err := bot.API.GetScores(user.EtternaID, "", topPageLookup, uint(start), sortColumn, false)
+
+		if err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		}
+
+		for i := range scores {
+			if len(plays) < count && matchesAllFilters(&scores[i], filters) {
+				plays = append(plays, scores[i])
+			}
+		}
+
+		if len(scores) < topPageLookup {
+			break
+		}
+	}
+
+	var lines []string
+
+	for i, s := range plays {
+		value := s.Nerfed
+
+		if skillset != nil {
+			value = s.MSD.Get(*skillset)
+		}
+
+		scoreURL := fmt.Sprintf(bot.API.BaseURL()+"/score/view/%s%d", s.Key, user.EtternaID)
+		lines = append(lines, fmt.Sprintf("**%d.** [%s (%sx)](%s) — %.2f%% — **%.2f**",
+			i+1, s.Song.Name, formatRate(s.Rate), scoreURL, s.Accuracy, value))
+	}
+
+	if len(lines) == 0 {
+		lines = []string{"No plays found."}
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Color: embedColor,
+		Author: &discordgo.MessageEmbedAuthor{
+			IconURL: bot.API.BaseURL() + "/avatars/" + user.Avatar,
+			Name:    fmt.Sprintf("Top plays by %s (%s)", user.Username, sortName),
+			URL:     bot.API.BaseURL() + "/user/" + user.Username,
+		},
+	}
+
+	if err := sendPaginatedEmbed(bot, m.ChannelID, embed, lines, defaultPageSize); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+	}
+}
+
+func matchesAllFilters(s *etterna.Score, filters []*scoreFilter) bool {
+	for _, f := range filters {
+		if !f.matches(s) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -31,6 +31,7 @@ func getUserOrCreate(bot *eb.Bot, username string, latest bool) (*model.EtternaU
 			Username:      etternaUser.Username,
 			EtternaID:     id,
 			Avatar:        etternaUser.AvatarURL,
+			CountryCode:   etternaUser.CountryCode,
 			MSDOverall:    etternaUser.MSD.Overall,
 			MSDStream:     etternaUser.MSD.Stream,
 			MSDJumpstream: etternaUser.MSD.Jumpstream,
@@ -69,6 +70,7 @@ func getLatestUserInfo(bot *eb.Bot, user *model.EtternaUser) error {
 	}
 
 	user.Avatar = etternaUser.AvatarURL
+	user.CountryCode = etternaUser.CountryCode
 	user.MSDOverall = util.RoundToPrecision(etternaUser.Overall, 2)
 	user.MSDStream = util.RoundToPrecision(etternaUser.Stream, 2)
 	user.MSDJumpstream = util.RoundToPrecision(etternaUser.Jumpstream, 2)
@@ -86,5 +88,8 @@ func getLatestUserInfo(bot *eb.Bot, user *model.EtternaUser) error {
 	user.RankChordjack = etternaUser.Rank.Chordjack
 	user.RankTechnical = etternaUser.Rank.Technical
 
+	syncRoles(bot, user)
+	syncNicknames(bot, user)
+
 	return nil
 }
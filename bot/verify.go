@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	eb "github.com/Kangaroux/etternabot"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	verificationCodePrefix = "etternabot-"
+	verificationExpiry     = time.Hour // How long a user has to put the code in their profile
+)
+
+// CmdVerify finishes registering the user once the code they were given by setuser
+// is found on their etterna profile. Admins can also require verification in the server
+func CmdVerify(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate, args []string) {
+	if len(args) == 1 {
+		completeVerification(bot, m)
+		return
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "cancel":
+		if err := bot.Verifications.Delete(m.GuildID, m.Author.ID); err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		}
+
+		bot.Session.ChannelMessageSend(m.ChannelID, "Cancelled your pending registration.")
+	case "required":
+		setVerificationRequired(bot, server, m, args[2:])
+	default:
+		bot.Session.ChannelMessageSend(m.ChannelID, "Usage: verify [cancel | required on/off]")
+	}
+}
+
+func setVerificationRequired(bot *eb.Bot, server *model.DiscordServer, m *discordgo.MessageCreate, args []string) {
+	if len(args) == 0 {
+		if server.RequireVerification {
+			bot.Session.ChannelMessageSend(m.ChannelID, "Users must verify their account to register in this server.")
+		} else {
+			bot.Session.ChannelMessageSend(m.ChannelID, "Verification is optional in this server.")
+		}
+
+		return
+	}
+
+	if !isServerAdmin(bot, m) {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You need the Manage Server permission to do that.")
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "on":
+		server.RequireVerification = true
+	case "off":
+		server.RequireVerification = false
+	default:
+		bot.Session.ChannelMessageSend(m.ChannelID, "Usage: verify required on/off")
+		return
+	}
+
+	if err := bot.Servers.Save(server); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	if server.RequireVerification {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Users must now verify their account to register. Existing registrations aren't affected.")
+	} else {
+		bot.Session.ChannelMessageSend(m.ChannelID, "Verification is now optional.")
+	}
+}
+
+// startVerification gives the user a code to put in their etterna profile, replacing
+// any registration they already had pending. If serverOnly is false, the user is linked
+// in every server once they're verified
+func startVerification(bot *eb.Bot, m *discordgo.MessageCreate, username string, serverOnly bool) {
+	code, err := newVerificationCode()
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	v := &model.Verification{
+		ServerID:      m.GuildID,
+		DiscordUserID: m.Author.ID,
+		Username:      username,
+		Code:          code,
+		ServerOnly:    serverOnly,
+	}
+
+	if err := bot.Verifications.Save(v); err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("To prove you own '%s', add `%s` to the about me section of "+
+		"your Etterna Online profile, then use `verify` within %d minutes. You can remove the code once you're registered.",
+		username, code, int(verificationExpiry.Minutes())))
+}
+
+// completeVerification registers or links the user if the code from their pending
+// registration is on the etterna user's profile
+func completeVerification(bot *eb.Bot, m *discordgo.MessageCreate) {
+	v, err := bot.Verifications.Get(m.GuildID, m.Author.ID)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if v == nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, "You don't have a pending registration. Use `setuser <username>` first.")
+		return
+	} else if time.Since(v.CreatedAt) > verificationExpiry {
+		bot.Verifications.Delete(m.GuildID, m.Author.ID)
+		bot.Session.ChannelMessageSend(m.ChannelID, "Your verification code expired. Use `setuser` again to get a new one.")
+		return
+	}
+
+	found, err := bot.API.ProfileContains(v.Username, v.Code)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if !found {
+		bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Couldn't find `%s` on %s/user/%s. "+
+			"Make sure you saved your profile and try again.", v.Code, bot.API.BaseURL(), v.Username))
+		return
+	}
+
+	// Someone else may have registered as the user while this was pending
+	discordID, err := bot.Users.GetRegisteredDiscordUserID(m.GuildID, v.Username)
+
+	if err != nil {
+		bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	} else if discordID != "" && discordID != m.Author.ID {
+		// Links that were never verified are replaced
+		unverified, err := hasUnverifiedLink(bot, discordID, v.Username)
+
+		if err != nil {
+			bot.Session.ChannelMessageSend(m.ChannelID, err.Error())
+			return
+		} else if !unverified {
+			bot.Session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Another user is already registered as '%s'. "+
+				"Ask an admin to remove their registration.", v.Username))
+			return
+		}
+	}
+
+	bot.Verifications.Delete(m.GuildID, m.Author.ID)
+
+	if v.ServerOnly {
+		registerHere(bot, m, v.Username)
+	} else {
+		linkUser(bot, m, v.Username, true)
+	}
+}
+
+// newVerificationCode returns a random code that is hard to guess
+func newVerificationCode() (string, error) {
+	b := make([]byte, 4)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return verificationCodePrefix + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,130 @@
+// Package chart renders simple charts as images. Everything is drawn in-process so
+// the bot doesn't rely on any external services for generating images.
+package chart
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	// Colors are chosen to blend in with discord's dark theme
+	Background = color.RGBA{0x2f, 0x31, 0x36, 0xff}
+	GridColor  = color.RGBA{0x4f, 0x54, 0x5c, 0xff}
+	TextColor  = color.RGBA{0xdc, 0xdd, 0xde, 0xff}
+
+	// Palette is a set of colors that are easy to tell apart, used for each line or
+	// shape in a chart
+	Palette = []color.RGBA{
+		{0xff, 0xff, 0xff, 0xff},
+		{0x5d, 0xad, 0xe2, 0xff},
+		{0xf3, 0x9c, 0x12, 0xff},
+		{0x2e, 0xcc, 0x71, 0xff},
+		{0xe7, 0x4c, 0x3c, 0xff},
+		{0x9b, 0x59, 0xb6, 0xff},
+		{0xf1, 0xc4, 0x0f, 0xff},
+		{0x1a, 0xbc, 0x9c, 0xff},
+	}
+)
+
+var face = basicfont.Face7x13
+
+// EncodePNG encodes the image as a PNG
+func EncodePNG(img image.Image) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+func newCanvas(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{Background}, image.ZP, draw.Src)
+
+	return img
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, &image.Uniform{c}, image.ZP, draw.Over)
+}
+
+// drawText draws a line of text where (x, y) is the top left corner of the text
+func drawText(img *image.RGBA, x, y int, s string, c color.Color) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  &image.Uniform{c},
+		Face: face,
+		Dot:  fixed.P(x, y+face.Ascent),
+	}
+
+	d.DrawString(s)
+}
+
+// drawTextCentered draws a line of text centered horizontally on x
+func drawTextCentered(img *image.RGBA, x, y int, s string, c color.Color) {
+	drawText(img, x-textWidth(s)/2, y, s, c)
+}
+
+func textWidth(s string) int {
+	return font.MeasureString(face, s).Ceil()
+}
+
+// drawLine draws a line with the given thickness by stamping a square along the
+// length of the line
+func drawLine(img *image.RGBA, x0, y0, x1, y1, thickness float64, c color.Color) {
+	length := math.Hypot(x1-x0, y1-y0)
+	steps := int(math.Ceil(length * 2))
+	half := thickness / 2
+
+	if steps == 0 {
+		steps = 1
+	}
+
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		x := x0 + (x1-x0)*t
+		y := y0 + (y1-y0)*t
+
+		r := image.Rect(
+			int(math.Round(x-half)),
+			int(math.Round(y-half)),
+			int(math.Round(x+half)),
+			int(math.Round(y+half)),
+		)
+
+		if r.Empty() {
+			r.Max = r.Min.Add(image.Pt(1, 1))
+		}
+
+		draw.Draw(img, r, &image.Uniform{c}, image.ZP, draw.Src)
+	}
+}
+
+// niceStep returns a step size close to the given value that is a 1, 2 or 5
+// multiplied by a power of 10. Used for spacing out axis labels
+func niceStep(raw float64) float64 {
+	if raw <= 0 {
+		return 1
+	}
+
+	magnitude := math.Pow10(int(math.Floor(math.Log10(raw))))
+
+	for _, m := range []float64{1, 2, 5} {
+		if raw <= m*magnitude {
+			return m * magnitude
+		}
+	}
+
+	return 10 * magnitude
+}
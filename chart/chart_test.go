@@ -0,0 +1,66 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNiceStep(t *testing.T) {
+	require.Equal(t, 1.0, niceStep(0))
+	require.Equal(t, 0.2, niceStep(0.15))
+	require.Equal(t, 1.0, niceStep(1))
+	require.Equal(t, 5.0, niceStep(3))
+	require.Equal(t, 10.0, niceStep(7))
+}
+
+func TestLineGraph(t *testing.T) {
+	t.Run("should draw the series", func(t *testing.T) {
+		now := time.Now()
+		img := LineGraph("test", []Series{
+			{
+				Name:  "Overall",
+				Color: Palette[1],
+				Points: []Point{
+					{Time: now.Add(-48 * time.Hour), Value: 20},
+					{Time: now, Value: 22},
+				},
+			},
+		})
+
+		require.Equal(t, image.Rect(0, 0, lineGraphWidth, lineGraphHeight), img.Bounds())
+
+		// The end of the line should be in the top right corner of the plot
+		_, _, b, _ := img.At(lineGraphWidth-lineGraphRight, lineGraphTop).RGBA()
+		require.Equal(t, uint32(Palette[1].B)*0x101, b)
+	})
+
+	t.Run("should handle no data", func(t *testing.T) {
+		img := LineGraph("test", nil)
+		require.Equal(t, image.Rect(0, 0, lineGraphWidth, lineGraphHeight), img.Bounds())
+	})
+}
+
+func TestHeatmap(t *testing.T) {
+	end := time.Date(2019, 12, 25, 15, 0, 0, 0, time.UTC)
+	img := Heatmap("test", map[time.Time]int{
+		time.Date(2019, 12, 25, 0, 0, 0, 0, time.UTC): 10,
+		time.Date(2019, 12, 24, 0, 0, 0, 0, time.UTC): 1,
+	}, end, 2)
+
+	step := heatmapCell + heatmapGap
+	require.Equal(t, image.Rect(0, 0, heatmapLeft+2*step+heatmapRight, heatmapTop+7*step+heatmapBottom), img.Bounds())
+
+	// Dec 25, 2019 was a Wednesday in the second (last) column
+	x := heatmapLeft + step + 1
+	require.Equal(t, heatmapShade(10, 10), img.At(x, heatmapTop+int(time.Wednesday)*step+1))
+	require.Equal(t, heatmapShade(1, 10), img.At(x, heatmapTop+int(time.Tuesday)*step+1))
+	require.Equal(t, GridColor, img.At(x, heatmapTop+int(time.Monday)*step+1))
+
+	// The busiest day gets the darkest shade and any activity is visible
+	require.Equal(t, color.RGBA{Palette[3].R, Palette[3].G, Palette[3].B, 0xff}, heatmapShade(10, 10))
+	require.NotEqual(t, GridColor, heatmapShade(1, 10))
+}
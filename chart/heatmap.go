@@ -0,0 +1,111 @@
+package chart
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+)
+
+const (
+	heatmapCell   = 12 // Size of each day's square
+	heatmapGap    = 3  // Space between the squares
+	heatmapLeft   = 40 // Space for the weekday labels
+	heatmapRight  = 20 // Padding on the right side
+	heatmapTop    = 46 // Space for the title and month labels
+	heatmapBottom = 36 // Space for the legend
+	heatmapLevels = 4  // Number of shades for days with activity
+)
+
+// Heatmap draws a calendar of the given number of weeks ending on the day of the end
+// time, where each day is shaded by how many plays were on that day. Counts are keyed
+// by the start of the day (UTC)
+func Heatmap(title string, counts map[time.Time]int, end time.Time, weeks int) image.Image {
+	step := heatmapCell + heatmapGap
+	width := heatmapLeft + weeks*step + heatmapRight
+	height := heatmapTop + 7*step + heatmapBottom
+
+	img := newCanvas(width, height)
+	drawTextCentered(img, width/2, 8, title, TextColor)
+
+	// Each column is a week starting on Sunday, and the last column is the week of the
+	// end day
+	last := truncateDay(end)
+	first := last.AddDate(0, 0, -int(last.Weekday())-(weeks-1)*7)
+
+	max := 0
+
+	for day, n := range counts {
+		if !day.Before(first) && !day.After(last) && n > max {
+			max = n
+		}
+	}
+
+	for i, label := range []string{"Mon", "Wed", "Fri"} {
+		y := heatmapTop + (2*i+1)*step + heatmapCell/2 - face.Ascent/2 - 1
+		drawText(img, heatmapLeft-textWidth(label)-6, y, label, TextColor)
+	}
+
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		week := int(day.Sub(first).Hours()/24) / 7
+		x := heatmapLeft + week*step
+		y := heatmapTop + int(day.Weekday())*step
+
+		// Label the first column of each month
+		if day.Day() <= 7 && day.Weekday() == time.Sunday {
+			drawText(img, x, heatmapTop-16, day.Format("Jan"), TextColor)
+		}
+
+		fillRect(img, image.Rect(x, y, x+heatmapCell, y+heatmapCell), heatmapShade(counts[day], max))
+	}
+
+	// Legend from least to most active
+	x := width - heatmapRight - (heatmapLevels+1)*step - textWidth("More") - textWidth("Less") - 12
+	y := height - heatmapBottom + 12
+
+	drawText(img, x, y, "Less", TextColor)
+	x += textWidth("Less") + 6
+
+	for level := 0; level <= heatmapLevels; level++ {
+		fillRect(img, image.Rect(x, y+1, x+heatmapCell, y+1+heatmapCell), heatmapShade(level, heatmapLevels))
+		x += step
+	}
+
+	drawText(img, x+2, y, "More", TextColor)
+
+	if max > 0 {
+		drawText(img, heatmapLeft, y, fmt.Sprintf("Busiest day: %d plays", max), TextColor)
+	}
+
+	return img
+}
+
+// heatmapShade returns the color of a day with the given count, where max is the
+// highest count on the heatmap. Days without any plays are drawn the same color as
+// the grid
+func heatmapShade(n, max int) color.Color {
+	if n <= 0 || max <= 0 {
+		return GridColor
+	}
+
+	// Round up so that any activity gets at least the lightest shade
+	level := (n*heatmapLevels + max - 1) / max
+	t := float64(level) / heatmapLevels
+	c := Palette[3]
+
+	return color.RGBA{
+		R: lerp(GridColor.R, c.R, t),
+		G: lerp(GridColor.G, c.G, t),
+		B: lerp(GridColor.B, c.B, t),
+		A: 0xff,
+	}
+}
+
+func lerp(a, b uint8, t float64) uint8 {
+	return uint8(float64(a) + (float64(b)-float64(a))*t)
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
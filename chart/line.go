@@ -0,0 +1,160 @@
+package chart
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"time"
+)
+
+const (
+	lineGraphWidth  = 800
+	lineGraphHeight = 400
+	lineGraphLeft   = 60  // Space for the y axis labels
+	lineGraphRight  = 20  // Padding on the right side
+	lineGraphTop    = 50  // Space for the title and legend
+	lineGraphBottom = 30  // Space for the x axis labels
+	lineGraphYTicks = 5   // Approximate number of y axis labels
+	lineGraphXTicks = 6   // Number of x axis labels
+	lineThickness   = 2.0 // Thickness of each series line
+)
+
+// Point is a single value at a point in time
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is a line on a line graph. Points should be sorted by time
+type Series struct {
+	Name   string
+	Color  color.Color
+	Points []Point
+}
+
+// LineGraph draws a line graph of the series with time along the x axis
+func LineGraph(title string, series []Series) image.Image {
+	img := newCanvas(lineGraphWidth, lineGraphHeight)
+	drawTextCentered(img, lineGraphWidth/2, 8, title, TextColor)
+
+	var minT, maxT time.Time
+	minV, maxV := math.Inf(1), math.Inf(-1)
+
+	for _, s := range series {
+		for _, p := range s.Points {
+			if minT.IsZero() || p.Time.Before(minT) {
+				minT = p.Time
+			}
+
+			if maxT.IsZero() || p.Time.After(maxT) {
+				maxT = p.Time
+			}
+
+			minV = math.Min(minV, p.Value)
+			maxV = math.Max(maxV, p.Value)
+		}
+	}
+
+	if minT.IsZero() {
+		drawTextCentered(img, lineGraphWidth/2, lineGraphHeight/2, "No data", TextColor)
+		return img
+	}
+
+	// Avoid dividing by zero when everything is at the same time or value
+	if !maxT.After(minT) {
+		minT = minT.Add(-12 * time.Hour)
+		maxT = maxT.Add(12 * time.Hour)
+	}
+
+	if maxV-minV < 1 {
+		mid := (maxV + minV) / 2
+		minV = mid - 0.5
+		maxV = mid + 0.5
+	}
+
+	step := niceStep((maxV - minV) / lineGraphYTicks)
+	minV = math.Floor(minV/step) * step
+	maxV = math.Ceil(maxV/step) * step
+
+	plot := image.Rect(lineGraphLeft, lineGraphTop, lineGraphWidth-lineGraphRight, lineGraphHeight-lineGraphBottom)
+
+	toX := func(t time.Time) float64 {
+		return float64(plot.Min.X) + float64(t.Sub(minT))/float64(maxT.Sub(minT))*float64(plot.Dx())
+	}
+
+	toY := func(v float64) float64 {
+		return float64(plot.Max.Y) - (v-minV)/(maxV-minV)*float64(plot.Dy())
+	}
+
+	// Horizontal grid lines and the y axis labels
+	for v := minV; v <= maxV+step/2; v += step {
+		y := int(math.Round(toY(v)))
+		label := formatAxisValue(v, step)
+
+		fillRect(img, image.Rect(plot.Min.X, y, plot.Max.X, y+1), GridColor)
+		drawText(img, plot.Min.X-textWidth(label)-6, y-face.Ascent/2-1, label, TextColor)
+	}
+
+	// Vertical grid lines and the x axis labels
+	layout := "Jan 2"
+
+	if maxT.Sub(minT) > 365*24*time.Hour {
+		layout = "Jan 2006"
+	}
+
+	for i := 0; i < lineGraphXTicks; i++ {
+		t := minT.Add(time.Duration(float64(maxT.Sub(minT)) * float64(i) / (lineGraphXTicks - 1)))
+		x := int(math.Round(toX(t)))
+
+		label := t.Format(layout)
+		labelX := x - textWidth(label)/2
+
+		// Keep the last label from going off the edge
+		if labelX+textWidth(label) > lineGraphWidth {
+			labelX = lineGraphWidth - textWidth(label) - 2
+		}
+
+		fillRect(img, image.Rect(x, plot.Min.Y, x+1, plot.Max.Y), GridColor)
+		drawText(img, labelX, plot.Max.Y+8, label, TextColor)
+	}
+
+	for _, s := range series {
+		for i := 1; i < len(s.Points); i++ {
+			a := s.Points[i-1]
+			b := s.Points[i]
+			drawLine(img, toX(a.Time), toY(a.Value), toX(b.Time), toY(b.Value), lineThickness, s.Color)
+		}
+
+		// A single point wouldn't otherwise be visible
+		if len(s.Points) == 1 {
+			p := s.Points[0]
+			drawLine(img, toX(p.Time)-3, toY(p.Value), toX(p.Time)+3, toY(p.Value), lineThickness*2, s.Color)
+		}
+	}
+
+	drawLegend(img, lineGraphLeft, 26, series)
+
+	return img
+}
+
+// drawLegend draws a row with a colored square and name for each series
+func drawLegend(img *image.RGBA, x, y int, series []Series) {
+	for _, s := range series {
+		fillRect(img, image.Rect(x, y+2, x+10, y+12), s.Color)
+		drawText(img, x+14, y, s.Name, TextColor)
+		x += 14 + textWidth(s.Name) + 16
+	}
+}
+
+// formatAxisValue formats a value on the axis with just enough decimal places to
+// tell the labels apart
+func formatAxisValue(v, step float64) string {
+	if step >= 1 {
+		return fmt.Sprintf("%.0f", v)
+	} else if step >= 0.1 {
+		return fmt.Sprintf("%.1f", v)
+	}
+
+	return fmt.Sprintf("%.2f", v)
+}
@@ -0,0 +1,197 @@
+package chart
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	radarWidth      = 520
+	radarHeight     = 560
+	radarHeader     = 90  // Space for the avatars and names
+	radarRadius     = 170 // Distance from the center to the end of each axis
+	radarRings      = 4   // Number of rings drawn for the scale
+	radarAvatarSize = 64
+	radarFillAlpha  = 0x50
+)
+
+// RadarSeries is a shape on a radar chart. There should be one value per axis.
+// Avatar is optional and is drawn in the header next to the name
+type RadarSeries struct {
+	Name   string
+	Color  color.RGBA
+	Values []float64
+	Avatar image.Image
+}
+
+// RadarChart draws a radar (spider) chart with an axis for each label. Up to two
+// series can be drawn, their names and avatars are shown in the header
+func RadarChart(axes []string, series []RadarSeries) image.Image {
+	img := newCanvas(radarWidth, radarHeight)
+	center := image.Pt(radarWidth/2, radarHeader+(radarHeight-radarHeader)/2)
+
+	for i, s := range series {
+		drawRadarHeader(img, s, axes, i == 1)
+	}
+
+	minV, maxV := math.Inf(1), math.Inf(-1)
+
+	for _, s := range series {
+		for _, v := range s.Values {
+			minV = math.Min(minV, v)
+			maxV = math.Max(maxV, v)
+		}
+	}
+
+	if math.IsInf(minV, 0) {
+		minV, maxV = 0, 1
+	}
+
+	// Don't start the scale at zero, otherwise everyone's chart is a circle since
+	// skillsets tend to be close together
+	step := niceStep(math.Max(maxV-minV, 1) / (radarRings - 1))
+	lo := math.Max(0, math.Floor(minV/step)*step-step)
+	hi := lo + step*radarRings
+
+	for hi < maxV {
+		hi += step
+	}
+
+	angle := func(i int) float64 {
+		return -math.Pi/2 + 2*math.Pi*float64(i)/float64(len(axes))
+	}
+
+	point := func(i int, v float64) (float64, float64) {
+		r := (v - lo) / (hi - lo) * radarRadius
+		r = math.Max(0, math.Min(r, radarRadius))
+
+		return float64(center.X) + r*math.Cos(angle(i)), float64(center.Y) + r*math.Sin(angle(i))
+	}
+
+	// Rings and their labels
+	for ring := 1; ring <= radarRings; ring++ {
+		v := lo + (hi-lo)*float64(ring)/radarRings
+
+		for i := range axes {
+			x0, y0 := point(i, v)
+			x1, y1 := point((i+1)%len(axes), v)
+			drawLine(img, x0, y0, x1, y1, 1, GridColor)
+		}
+
+		label := formatAxisValue(v, step)
+		_, y := point(0, v)
+		drawText(img, center.X+4, int(y)-face.Ascent-2, label, GridColor)
+	}
+
+	// Axes and their labels
+	for i, name := range axes {
+		x, y := point(i, hi)
+		drawLine(img, float64(center.X), float64(center.Y), x, y, 1, GridColor)
+
+		lx := float64(center.X) + (radarRadius+16)*math.Cos(angle(i))
+		ly := float64(center.Y) + (radarRadius+16)*math.Sin(angle(i))
+		drawTextCentered(img, int(lx), int(ly)-face.Ascent/2, name, TextColor)
+	}
+
+	for _, s := range series {
+		var polygon [][2]float64
+
+		for i := range axes {
+			var v float64
+
+			if i < len(s.Values) {
+				v = s.Values[i]
+			}
+
+			x, y := point(i, v)
+			polygon = append(polygon, [2]float64{x, y})
+		}
+
+		fill := color.NRGBA{s.Color.R, s.Color.G, s.Color.B, radarFillAlpha}
+		fillPolygon(img, polygon, fill)
+
+		for i := range polygon {
+			a := polygon[i]
+			b := polygon[(i+1)%len(polygon)]
+			drawLine(img, a[0], a[1], b[0], b[1], lineThickness, s.Color)
+		}
+	}
+
+	return img
+}
+
+// drawRadarHeader draws the avatar, name and first value of a series at the top of
+// the chart. The second series is drawn on the right side
+func drawRadarHeader(img *image.RGBA, s RadarSeries, axes []string, right bool) {
+	x := 16
+	y := (radarHeader - radarAvatarSize) / 2
+
+	if right {
+		x = radarWidth - 16 - radarAvatarSize
+	}
+
+	if s.Avatar != nil {
+		r := image.Rect(x, y, x+radarAvatarSize, y+radarAvatarSize)
+		xdraw.ApproxBiLinear.Scale(img, r, s.Avatar, s.Avatar.Bounds(), xdraw.Over, nil)
+	}
+
+	nameX := x + radarAvatarSize + 10
+	values := ""
+
+	if len(s.Values) > 0 && len(axes) > 0 {
+		values = fmt.Sprintf("%s: %.2f", axes[0], s.Values[0])
+	}
+
+	if right {
+		nameX = x - 10 - textWidth(s.Name)
+	}
+
+	nameY := radarHeader/2 - face.Height
+	drawText(img, nameX, nameY, s.Name, s.Color)
+
+	if right {
+		drawText(img, x-10-textWidth(values), nameY+face.Height+4, values, TextColor)
+	} else {
+		drawText(img, nameX, nameY+face.Height+4, values, TextColor)
+	}
+}
+
+// fillPolygon fills a polygon using a scanline fill, blending the color with the
+// image beneath it
+func fillPolygon(img *image.RGBA, points [][2]float64, c color.Color) {
+	if len(points) < 3 {
+		return
+	}
+
+	minY, maxY := math.Inf(1), math.Inf(-1)
+
+	for _, p := range points {
+		minY = math.Min(minY, p[1])
+		maxY = math.Max(maxY, p[1])
+	}
+
+	for y := int(math.Ceil(minY)); y <= int(math.Floor(maxY)); y++ {
+		fy := float64(y) + 0.5
+		var xs []float64
+
+		for i := range points {
+			a := points[i]
+			b := points[(i+1)%len(points)]
+
+			if (a[1] <= fy && b[1] > fy) || (b[1] <= fy && a[1] > fy) {
+				xs = append(xs, a[0]+(fy-a[1])/(b[1]-a[1])*(b[0]-a[0]))
+			}
+		}
+
+		sort.Float64s(xs)
+
+		for i := 0; i+1 < len(xs); i += 2 {
+			fillRect(img, image.Rect(int(math.Round(xs[i])), y, int(math.Round(xs[i+1])), y+1), c)
+		}
+	}
+}
@@ -39,6 +39,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Reading commands requires the message content intent, and knowing which servers
+	// linked users are in requires the guild members intent. Both are privileged and
+	// must also be enabled for the bot in the developer portal
+	dg.Identify.Intents = discordgo.IntentsAllWithoutPrivileged | discordgo.IntentMessageContent | discordgo.IntentGuildMembers
+
 	db, err := connectDB(getenv("DATABASE_HOST"),
 		getenv("POSTGRES_DB"),
 		getenv("POSTGRES_USER"),
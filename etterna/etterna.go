@@ -1,6 +1,7 @@
 package etterna
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -240,6 +241,40 @@ func (api *EtternaAPI) GetUserID(username string) (int, error) {
 	return id, nil
 }
 
+// ProfileContains returns whether the user's profile page contains the given text.
+// Used to verify that someone owns an account by having them put a code in their
+// profile's about me.
+func (api *EtternaAPI) ProfileContains(username, text string) (bool, error) {
+	resp, err := http.Get(api.baseURL + "/user/" + username)
+
+	if err != nil {
+		return false, &Error{
+			Code:    ErrUnexpected,
+			Context: err,
+			Msg:     "Unexpected error trying to look up user profile.",
+		}
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, &Error{
+			Code: ErrNotFound,
+			Msg:  "No user with that username exists.",
+		}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return false, &Error{
+			Code:    ErrUnexpected,
+			Context: err,
+			Msg:     "Unexpected error trying to look up user profile.",
+		}
+	}
+
+	return bytes.Contains(body, []byte(text)), nil
+}
+
 // GetScores returns a list of valid scores for a given user.
 func (api *EtternaAPI) GetScores(userID int, search string, n uint, start uint, sortColumn SortColumn, sortAsc bool) ([]Score, error) {
 	var payload struct {
@@ -170,6 +170,46 @@ func TestGetUserID(t *testing.T) {
 	})
 }
 
+func TestProfileContains(t *testing.T) {
+	t.Run("should error when not found", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+
+		defer server.Close()
+
+		api := New("testkey")
+		api.baseURL = server.URL
+
+		_, err := api.ProfileContains("jesse", "code")
+
+		require.Error(t, err)
+		require.Equal(t, ErrNotFound, err.(*Error).Code)
+	})
+
+	t.Run("should find the text in the profile", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/user/jesse", r.URL.RequestURI())
+			w.Write([]byte(`<div class="aboutme">hello etternabot-abc123</div>`))
+		}))
+
+		defer server.Close()
+
+		api := New("testkey")
+		api.baseURL = server.URL
+
+		found, err := api.ProfileContains("jesse", "etternabot-abc123")
+
+		require.NoError(t, err)
+		require.True(t, found)
+
+		found, err = api.ProfileContains("jesse", "etternabot-xyz789")
+
+		require.NoError(t, err)
+		require.False(t, found)
+	})
+}
+
 func TestGetScores(t *testing.T) {
 	t.Run("should send the right request", func(t *testing.T) {
 		start := 10
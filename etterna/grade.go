@@ -0,0 +1,53 @@
+package etterna
+
+import "strings"
+
+// Grade is the letter grade of a score, which is based on its accuracy
+type Grade string
+
+const (
+	GradeAAAA Grade = "AAAA"
+	GradeAAA  Grade = "AAA"
+	GradeAA   Grade = "AA"
+	GradeA    Grade = "A"
+	GradeB    Grade = "B"
+	GradeC    Grade = "C"
+	GradeD    Grade = "D"
+)
+
+// Grades is every grade from best to worst
+var Grades = []Grade{GradeAAAA, GradeAAA, GradeAA, GradeA, GradeB, GradeC, GradeD}
+
+var gradeMinAccuracy = map[Grade]float64{
+	GradeAAAA: 99.955,
+	GradeAAA:  99.70,
+	GradeAA:   93.00,
+	GradeA:    80.00,
+	GradeB:    70.00,
+	GradeC:    60.00,
+	GradeD:    0,
+}
+
+// MinAccuracy returns the lowest accuracy that gets the grade
+func (g Grade) MinAccuracy() float64 {
+	return gradeMinAccuracy[g]
+}
+
+// GetGrade returns the grade of a score with the given accuracy
+func GetGrade(accuracy float64) Grade {
+	for _, g := range Grades {
+		if accuracy >= g.MinAccuracy() {
+			return g
+		}
+	}
+
+	return GradeD
+}
+
+// ParseGrade returns the grade with the given name (case insensitive)
+func ParseGrade(name string) (Grade, bool) {
+	g := Grade(strings.ToUpper(name))
+	_, ok := gradeMinAccuracy[g]
+
+	return g, ok
+}
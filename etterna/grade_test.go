@@ -0,0 +1,25 @@
+package etterna
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetGrade(t *testing.T) {
+	require.Equal(t, GradeAAAA, GetGrade(100))
+	require.Equal(t, GradeAAAA, GetGrade(99.955))
+	require.Equal(t, GradeAAA, GetGrade(99.95))
+	require.Equal(t, GradeAA, GetGrade(93))
+	require.Equal(t, GradeC, GetGrade(65))
+	require.Equal(t, GradeD, GetGrade(12))
+}
+
+func TestParseGrade(t *testing.T) {
+	g, ok := ParseGrade("aaa")
+	require.True(t, ok)
+	require.Equal(t, GradeAAA, g)
+
+	_, ok = ParseGrade("S")
+	require.False(t, ok)
+}
@@ -0,0 +1,137 @@
+package etterna
+
+import "strings"
+
+// Skillset is one of the rating categories that EO tracks for users and scores
+type Skillset int
+
+const (
+	SkillsetOverall Skillset = iota
+	SkillsetStream
+	SkillsetJumpstream
+	SkillsetHandstream
+	SkillsetStamina
+	SkillsetJackSpeed
+	SkillsetChordjack
+	SkillsetTechnical
+)
+
+// Skillsets is every skillset in the order EO displays them
+var Skillsets = []Skillset{
+	SkillsetOverall,
+	SkillsetStream,
+	SkillsetJumpstream,
+	SkillsetHandstream,
+	SkillsetStamina,
+	SkillsetJackSpeed,
+	SkillsetChordjack,
+	SkillsetTechnical,
+}
+
+var skillsetNames = map[Skillset]string{
+	SkillsetOverall:    "Overall",
+	SkillsetStream:     "Stream",
+	SkillsetJumpstream: "Jumpstream",
+	SkillsetHandstream: "Handstream",
+	SkillsetStamina:    "Stamina",
+	SkillsetJackSpeed:  "JackSpeed",
+	SkillsetChordjack:  "Chordjack",
+	SkillsetTechnical:  "Technical",
+}
+
+// Shorthand names people commonly use for skillsets
+var skillsetAliases = map[string]Skillset{
+	"ovr":   SkillsetOverall,
+	"js":    SkillsetJumpstream,
+	"hs":    SkillsetHandstream,
+	"stam":  SkillsetStamina,
+	"jack":  SkillsetJackSpeed,
+	"jacks": SkillsetJackSpeed,
+	"cj":    SkillsetChordjack,
+	"tech":  SkillsetTechnical,
+}
+
+func (s Skillset) String() string {
+	return skillsetNames[s]
+}
+
+// SortColumn returns the column for sorting scores by this skillset
+func (s Skillset) SortColumn() SortColumn {
+	switch s {
+	case SkillsetStream:
+		return SortStream
+	case SkillsetJumpstream:
+		return SortJumpstream
+	case SkillsetHandstream:
+		return SortHandstream
+	case SkillsetStamina:
+		return SortStamina
+	case SkillsetJackSpeed:
+		return SortJackSpeed
+	case SkillsetChordjack:
+		return SortChordjack
+	case SkillsetTechnical:
+		return SortTechnical
+	default:
+		return SortOverall
+	}
+}
+
+// ParseSkillset looks up a skillset by its name or a common alias. The name is
+// not case sensitive
+func ParseSkillset(name string) (Skillset, bool) {
+	name = strings.ToLower(name)
+
+	for s, n := range skillsetNames {
+		if strings.ToLower(n) == name {
+			return s, true
+		}
+	}
+
+	s, ok := skillsetAliases[name]
+	return s, ok
+}
+
+// Get returns the rating for the given skillset
+func (m MSD) Get(s Skillset) float64 {
+	switch s {
+	case SkillsetStream:
+		return m.Stream
+	case SkillsetJumpstream:
+		return m.Jumpstream
+	case SkillsetHandstream:
+		return m.Handstream
+	case SkillsetStamina:
+		return m.Stamina
+	case SkillsetJackSpeed:
+		return m.JackSpeed
+	case SkillsetChordjack:
+		return m.Chordjack
+	case SkillsetTechnical:
+		return m.Technical
+	default:
+		return m.Overall
+	}
+}
+
+// Get returns the rank for the given skillset
+func (r Rank) Get(s Skillset) int {
+	switch s {
+	case SkillsetStream:
+		return r.Stream
+	case SkillsetJumpstream:
+		return r.Jumpstream
+	case SkillsetHandstream:
+		return r.Handstream
+	case SkillsetStamina:
+		return r.Stamina
+	case SkillsetJackSpeed:
+		return r.JackSpeed
+	case SkillsetChordjack:
+		return r.Chordjack
+	case SkillsetTechnical:
+		return r.Technical
+	default:
+		return r.Overall
+	}
+}
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE discord_servers
+DROP COLUMN rank_milestones;
+
+COMMIT;
\ No newline at end of file
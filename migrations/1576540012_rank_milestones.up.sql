@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE discord_servers
+ADD COLUMN rank_milestones INTEGER[] NOT NULL DEFAULT '{1000,500,100}';
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS scores;
+
+COMMIT;
\ No newline at end of file
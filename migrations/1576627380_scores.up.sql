@@ -0,0 +1,38 @@
+BEGIN;
+
+-- Every score the bot has seen, so we don't need to go back to EO to find them
+CREATE TABLE scores (
+    id             SERIAL PRIMARY KEY,
+    created_at     TIMESTAMP NOT NULL,
+    updated_at     TIMESTAMP NOT NULL,
+    score_key      VARCHAR(64) NOT NULL UNIQUE,
+    user_id        INTEGER NOT NULL REFERENCES etterna_users(id) ON DELETE CASCADE,
+    song_id        INTEGER NOT NULL REFERENCES songs(etterna_id),
+    rate           DECIMAL(4, 2) NOT NULL,
+    accuracy       DECIMAL(7, 4) NOT NULL,
+    marvelous      INTEGER NOT NULL,
+    perfect        INTEGER NOT NULL,
+    great          INTEGER NOT NULL,
+    good           INTEGER NOT NULL,
+    bad            INTEGER NOT NULL,
+    miss           INTEGER NOT NULL,
+    max_combo      INTEGER NOT NULL,
+    mines_hit      INTEGER NOT NULL,
+    msd_overall    DECIMAL(4, 2) NOT NULL,
+    msd_stream     DECIMAL(4, 2) NOT NULL,
+    msd_jumpstream DECIMAL(4, 2) NOT NULL,
+    msd_handstream DECIMAL(4, 2) NOT NULL,
+    msd_stamina    DECIMAL(4, 2) NOT NULL,
+    msd_jackspeed  DECIMAL(4, 2) NOT NULL,
+    msd_chordjack  DECIMAL(4, 2) NOT NULL,
+    msd_technical  DECIMAL(4, 2) NOT NULL,
+    nerf           DECIMAL(4, 2) NOT NULL,
+    mods           VARCHAR(255) NOT NULL,
+    valid          BOOLEAN NOT NULL,
+    played_at      TIMESTAMP NOT NULL
+);
+
+CREATE INDEX scores_user_id_song_id
+ON scores (user_id, song_id);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,46 @@
+BEGIN;
+
+-- Append-only log of a user's ratings and ranks. A new row is added each time
+-- either of them change
+CREATE TABLE rating_history (
+    id              SERIAL PRIMARY KEY,
+    created_at      TIMESTAMP NOT NULL,
+    user_id         INTEGER NOT NULL REFERENCES etterna_users(id) ON DELETE CASCADE,
+    msd_overall     DECIMAL(4, 2) NOT NULL,
+    msd_stream      DECIMAL(4, 2) NOT NULL,
+    msd_jumpstream  DECIMAL(4, 2) NOT NULL,
+    msd_handstream  DECIMAL(4, 2) NOT NULL,
+    msd_stamina     DECIMAL(4, 2) NOT NULL,
+    msd_jackspeed   DECIMAL(4, 2) NOT NULL,
+    msd_chordjack   DECIMAL(4, 2) NOT NULL,
+    msd_technical   DECIMAL(4, 2) NOT NULL,
+    rank_overall    INTEGER NOT NULL,
+    rank_stream     INTEGER NOT NULL,
+    rank_jumpstream INTEGER NOT NULL,
+    rank_handstream INTEGER NOT NULL,
+    rank_stamina    INTEGER NOT NULL,
+    rank_jackspeed  INTEGER NOT NULL,
+    rank_chordjack  INTEGER NOT NULL,
+    rank_technical  INTEGER NOT NULL
+);
+
+CREATE INDEX rating_history_user_id_created_at
+ON rating_history (user_id, created_at);
+
+-- Use the current ratings as the starting point
+INSERT INTO rating_history (
+    created_at, user_id,
+    msd_overall, msd_stream, msd_jumpstream, msd_handstream,
+    msd_stamina, msd_jackspeed, msd_chordjack, msd_technical,
+    rank_overall, rank_stream, rank_jumpstream, rank_handstream,
+    rank_stamina, rank_jackspeed, rank_chordjack, rank_technical
+)
+SELECT
+    updated_at, id,
+    msd_overall, msd_stream, msd_jumpstream, msd_handstream,
+    msd_stamina, msd_jackspeed, msd_chordjack, msd_technical,
+    rank_overall, rank_stream, rank_jumpstream, rank_handstream,
+    rank_stamina, rank_jackspeed, rank_chordjack, rank_technical
+FROM etterna_users;
+
+COMMIT;
\ No newline at end of file
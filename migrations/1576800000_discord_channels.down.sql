@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS discord_channels;
+
+COMMIT;
\ No newline at end of file
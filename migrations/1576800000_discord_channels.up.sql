@@ -0,0 +1,14 @@
+BEGIN;
+
+-- Per-channel state, so posting a score in one channel doesn't change what
+-- compare means in another
+CREATE TABLE discord_channels (
+    id           SERIAL PRIMARY KEY,
+    created_at   TIMESTAMP NOT NULL,
+    updated_at   TIMESTAMP NOT NULL,
+    server_id    VARCHAR(20) NOT NULL REFERENCES discord_servers(server_id) ON DELETE CASCADE,
+    channel_id   VARCHAR(20) NOT NULL UNIQUE,
+    last_song_id INTEGER
+);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- The score that each score summary posted by the bot is for, so replying to one
+-- with a command can use its song and rate
+CREATE TABLE posted_scores (
+    id         SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    message_id VARCHAR(20) NOT NULL UNIQUE,
+    channel_id VARCHAR(20) NOT NULL,
+    song_id    INTEGER NOT NULL,
+    score_key  VARCHAR(64) NOT NULL,
+    rate       DECIMAL(4, 2) NOT NULL
+);
+
+COMMIT;
\ No newline at end of file
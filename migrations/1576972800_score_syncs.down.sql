@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS score_syncs;
+
+COMMIT;
\ No newline at end of file
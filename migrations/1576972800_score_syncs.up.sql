@@ -0,0 +1,9 @@
+BEGIN;
+
+-- When each user's score list was last synced from EO
+CREATE TABLE score_syncs (
+    user_id   INTEGER PRIMARY KEY REFERENCES etterna_users(id) ON DELETE CASCADE,
+    synced_at TIMESTAMP NOT NULL
+);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE rivals (
+    id              SERIAL PRIMARY KEY,
+    created_at      TIMESTAMP NOT NULL,
+    updated_at      TIMESTAMP NOT NULL,
+    user_id         INTEGER NOT NULL REFERENCES etterna_users(id) ON DELETE CASCADE,
+    rival_id        INTEGER NOT NULL REFERENCES etterna_users(id) ON DELETE CASCADE,
+    discord_user_id VARCHAR(20) NOT NULL,
+
+    UNIQUE (user_id, rival_id)
+);
+
+CREATE INDEX rivals_rival_id
+ON rivals (rival_id);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS goals;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,19 @@
+BEGIN;
+
+CREATE TABLE goals (
+    id           SERIAL PRIMARY KEY,
+    created_at   TIMESTAMP NOT NULL,
+    updated_at   TIMESTAMP NOT NULL,
+    user_id      INTEGER NOT NULL REFERENCES etterna_users(id) ON DELETE CASCADE,
+    kind         VARCHAR(16) NOT NULL,
+    skillset     INTEGER NOT NULL,
+    target       DECIMAL(10, 4) NOT NULL,
+    song_id      INTEGER,
+    rate         DECIMAL(4, 2) NOT NULL,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX goals_user_id
+ON goals (user_id);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,14 @@
+BEGIN;
+
+CREATE TABLE achievements (
+    id          SERIAL PRIMARY KEY,
+    created_at  TIMESTAMP NOT NULL,
+    updated_at  TIMESTAMP NOT NULL,
+    user_id     INTEGER NOT NULL REFERENCES etterna_users(id) ON DELETE CASCADE,
+    achievement VARCHAR(32) NOT NULL,
+    score_key   VARCHAR(64) NOT NULL,
+
+    UNIQUE (user_id, achievement)
+);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE discord_servers
+DROP COLUMN digest_frequency,
+DROP COLUMN digest_channel_id,
+DROP COLUMN digest_sections,
+DROP COLUMN last_digest_at;
+
+COMMIT;
\ No newline at end of file
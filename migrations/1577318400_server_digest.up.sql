@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE discord_servers
+ADD COLUMN digest_frequency VARCHAR(8) NOT NULL DEFAULT 'off',
+ADD COLUMN digest_channel_id VARCHAR(20),
+ADD COLUMN digest_sections TEXT[] NOT NULL DEFAULT '{gains,plays,active,pbs,ranks}',
+ADD COLUMN last_digest_at TIMESTAMP;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS challenge_entries;
+DROP TABLE IF EXISTS challenges;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,31 @@
+BEGIN;
+
+-- Score challenges that are run in a server, e.g. "chart of the week"
+CREATE TABLE challenges (
+    id           SERIAL PRIMARY KEY,
+    created_at   TIMESTAMP NOT NULL,
+    updated_at   TIMESTAMP NOT NULL,
+    server_id    VARCHAR(20) NOT NULL REFERENCES discord_servers(server_id) ON DELETE CASCADE,
+    channel_id   VARCHAR(20) NOT NULL,
+    song_id      INTEGER NOT NULL REFERENCES songs(etterna_id),
+    rates        DECIMAL(4, 2)[] NOT NULL,
+    scoring      VARCHAR(8) NOT NULL,
+    starts_at    TIMESTAMP NOT NULL,
+    ends_at      TIMESTAMP NOT NULL,
+    announced_at TIMESTAMP
+);
+
+CREATE INDEX challenges_server_id
+ON challenges (server_id);
+
+-- Scores that were submitted to a challenge
+CREATE TABLE challenge_entries (
+    id           SERIAL PRIMARY KEY,
+    created_at   TIMESTAMP NOT NULL,
+    challenge_id INTEGER NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    score_key    VARCHAR(64) NOT NULL REFERENCES scores(score_key) ON DELETE CASCADE,
+
+    UNIQUE (challenge_id, score_key)
+);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS match_maps;
+DROP TABLE IF EXISTS matches;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,43 @@
+BEGIN;
+
+-- 1v1 matches between two players in a server
+CREATE TABLE matches (
+    id         SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    server_id  VARCHAR(20) NOT NULL REFERENCES discord_servers(server_id) ON DELETE CASCADE,
+    channel_id VARCHAR(20) NOT NULL,
+    message_id VARCHAR(20),
+    player1_id INTEGER NOT NULL REFERENCES etterna_users(id) ON DELETE CASCADE,
+    player2_id INTEGER NOT NULL REFERENCES etterna_users(id) ON DELETE CASCADE,
+    best_of    INTEGER NOT NULL,
+    bans       INTEGER NOT NULL,
+    status     VARCHAR(16) NOT NULL,
+    turn_id    INTEGER NOT NULL REFERENCES etterna_users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX matches_channel_id
+ON matches (channel_id);
+
+-- The map pool of a match, along with the picks, bans and results
+CREATE TABLE match_maps (
+    id               SERIAL PRIMARY KEY,
+    created_at       TIMESTAMP NOT NULL,
+    updated_at       TIMESTAMP NOT NULL,
+    match_id         INTEGER NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    song_id          INTEGER NOT NULL REFERENCES songs(etterna_id),
+    rate             DECIMAL(4, 2) NOT NULL,
+    status           VARCHAR(16) NOT NULL,
+    chosen_by        INTEGER REFERENCES etterna_users(id) ON DELETE CASCADE,
+    picked_at        TIMESTAMP,
+    player1_last_key VARCHAR(64),
+    player2_last_key VARCHAR(64),
+    player1_accuracy DECIMAL(7, 4),
+    player2_accuracy DECIMAL(7, 4),
+    winner_id        INTEGER REFERENCES etterna_users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX match_maps_match_id
+ON match_maps (match_id);
+
+COMMIT;
\ No newline at end of file
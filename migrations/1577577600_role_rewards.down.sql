@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS role_rewards;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,17 @@
+BEGIN;
+
+-- Discord roles that are given to players who reach a rating or rank
+CREATE TABLE role_rewards (
+    id         SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    server_id  VARCHAR(20) NOT NULL REFERENCES discord_servers(server_id) ON DELETE CASCADE,
+    role_id    VARCHAR(20) NOT NULL,
+    kind       VARCHAR(16) NOT NULL,
+    skillset   INTEGER NOT NULL,
+    threshold  DECIMAL(10, 4) NOT NULL,
+
+    UNIQUE (server_id, role_id)
+);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE etterna_users
+DROP COLUMN country_code;
+
+ALTER TABLE discord_servers
+DROP COLUMN nickname_template;
+
+COMMIT;
\ No newline at end of file
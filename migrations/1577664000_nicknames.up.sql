@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE etterna_users
+ADD COLUMN country_code VARCHAR(8) NOT NULL DEFAULT '';
+
+ALTER TABLE discord_servers
+ADD COLUMN nickname_template VARCHAR(64);
+
+COMMIT;
\ No newline at end of file
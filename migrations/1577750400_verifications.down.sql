@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE verifications;
+
+ALTER TABLE discord_servers
+DROP COLUMN require_verification;
+
+COMMIT;
\ No newline at end of file
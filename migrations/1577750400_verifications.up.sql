@@ -0,0 +1,20 @@
+BEGIN;
+
+ALTER TABLE discord_servers
+ADD COLUMN require_verification BOOLEAN NOT NULL DEFAULT false;
+
+-- Codes that discord users must put in their etterna profile to prove they own the
+-- account before they are registered
+CREATE TABLE verifications (
+    id              SERIAL PRIMARY KEY,
+    created_at      TIMESTAMP NOT NULL,
+    updated_at      TIMESTAMP NOT NULL,
+    server_id       VARCHAR(20) NOT NULL REFERENCES discord_servers(server_id) ON DELETE CASCADE,
+    discord_user_id VARCHAR(20) NOT NULL,
+    username        VARCHAR(32) NOT NULL,
+    code            VARCHAR(32) NOT NULL,
+
+    UNIQUE (server_id, discord_user_id)
+);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,26 @@
+BEGIN;
+
+-- Turn the global links back into per-server registrations
+INSERT INTO users_discord_servers (server_id, username, discord_user_id)
+SELECT r.server_id, r.username, r.discord_user_id
+FROM registrations r
+WHERE NOT EXISTS (
+    SELECT 1 FROM users_discord_servers o
+    WHERE o.server_id=r.server_id AND o.discord_user_id=r.discord_user_id
+);
+
+DELETE FROM users_discord_servers
+WHERE opted_out;
+
+DROP VIEW registrations;
+
+ALTER TABLE verifications
+DROP COLUMN server_only;
+
+ALTER TABLE users_discord_servers
+DROP COLUMN opted_out;
+
+DROP TABLE discord_members;
+DROP TABLE discord_links;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,80 @@
+BEGIN;
+
+-- Links a discord user to an etterna user in every server they're in
+CREATE TABLE discord_links (
+    id              SERIAL PRIMARY KEY,
+    created_at      TIMESTAMP NOT NULL,
+    updated_at      TIMESTAMP NOT NULL,
+    discord_user_id VARCHAR(20) NOT NULL UNIQUE,
+    username        VARCHAR(32) NOT NULL,
+    verified        BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE UNIQUE INDEX unique_icase_link_username
+ON discord_links (lower(username));
+
+-- The servers that linked discord users are members of
+CREATE TABLE discord_members (
+    server_id       VARCHAR(20) NOT NULL REFERENCES discord_servers(server_id) ON DELETE CASCADE,
+    discord_user_id VARCHAR(20) NOT NULL REFERENCES discord_links(discord_user_id) ON DELETE CASCADE,
+
+    PRIMARY KEY (server_id, discord_user_id)
+);
+
+-- Per-server registrations now override the global link in that server. An opted out
+-- row means the discord user isn't registered in the server at all
+ALTER TABLE users_discord_servers
+ADD COLUMN opted_out BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE verifications
+ADD COLUMN server_only BOOLEAN NOT NULL DEFAULT false;
+
+-- Discord users who are registered as the same etterna user in every server (and
+-- nobody else is registered as that user) become global links. Everyone else keeps
+-- their per-server registrations. The links aren't verified, so registrations in
+-- servers that require verification are kept to override the link there
+WITH candidates AS (
+    SELECT discord_user_id, min(username) AS username
+    FROM users_discord_servers
+    GROUP BY discord_user_id
+    HAVING count(DISTINCT lower(username)) = 1
+)
+INSERT INTO discord_links (created_at, updated_at, discord_user_id, username)
+SELECT now() AT TIME ZONE 'utc', now() AT TIME ZONE 'utc', c.discord_user_id, c.username
+FROM candidates c
+WHERE NOT EXISTS (
+    SELECT 1 FROM users_discord_servers o
+    WHERE lower(o.username)=lower(c.username) AND o.discord_user_id <> c.discord_user_id
+);
+
+INSERT INTO discord_members (server_id, discord_user_id)
+SELECT uds.server_id, uds.discord_user_id
+FROM users_discord_servers uds
+INNER JOIN discord_links l ON l.discord_user_id=uds.discord_user_id;
+
+DELETE FROM users_discord_servers uds
+USING discord_links l, discord_servers s
+WHERE l.discord_user_id=uds.discord_user_id
+AND s.server_id=uds.server_id
+AND NOT s.require_verification;
+
+-- The etterna user that each discord user is registered as in each server. Per-server
+-- registrations take priority over global links, and global links only apply in
+-- servers that require verification if they were verified
+CREATE VIEW registrations AS
+SELECT server_id, username, discord_user_id
+FROM users_discord_servers
+WHERE NOT opted_out
+UNION ALL
+SELECT m.server_id, l.username, l.discord_user_id
+FROM discord_links l
+INNER JOIN discord_members m ON m.discord_user_id=l.discord_user_id
+INNER JOIN discord_servers s ON s.server_id=m.server_id
+WHERE (l.verified OR NOT s.require_verification)
+AND NOT EXISTS (
+    SELECT 1 FROM users_discord_servers o
+    WHERE o.server_id=m.server_id
+    AND (o.discord_user_id=l.discord_user_id OR (lower(o.username)=lower(l.username) AND NOT o.opted_out))
+);
+
+COMMIT;
\ No newline at end of file
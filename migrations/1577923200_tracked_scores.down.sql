@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE scores
+DROP COLUMN tracked;
+
+COMMIT;
\ No newline at end of file
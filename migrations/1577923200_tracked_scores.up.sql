@@ -0,0 +1,15 @@
+BEGIN;
+
+-- Whether the score was seen by the recent plays tracker, as opposed to being synced
+-- from the user's score list
+ALTER TABLE scores
+ADD COLUMN tracked BOOLEAN NOT NULL DEFAULT false;
+
+-- Scores looked up by commands can't be told apart from tracked plays, so only each
+-- user's last recent play (the one the tracker remembers) is marked as tracked
+UPDATE scores s
+SET tracked = true
+FROM etterna_users u
+WHERE u.id=s.user_id AND u.last_recent_score_key=s.score_key;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,17 @@
+package model
+
+type AchievementServicer interface {
+	// Unlocks the achievement for the user. Returns false if it was already unlocked
+	Unlock(userID uint, achievementID, scoreKey string) (bool, error)
+
+	// Gets the achievements the user has unlocked, oldest first
+	GetUnlocked(userID uint) ([]*Achievement, error)
+}
+
+// Achievement is an achievement that a user has unlocked
+type Achievement struct {
+	BaseModel
+	UserID        uint   `db:"user_id"`
+	AchievementID string `db:"achievement"` // Which achievement was unlocked
+	ScoreKey      string `db:"score_key"`   // The score that unlocked it
+}
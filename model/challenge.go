@@ -0,0 +1,69 @@
+package model
+
+import (
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ChallengeServicer interface {
+	// Gets the challenge with the given ID
+	Get(challengeID uint) (*Challenge, error)
+
+	// Updates/creates the challenge
+	Save(challenge *Challenge) error
+
+	// Deletes the challenge and its entries
+	Delete(challengeID uint) error
+
+	// Gets the server's challenges that haven't ended yet, including ones that haven't
+	// started, ordered by when they end
+	GetUpcoming(serverID string) ([]*Challenge, error)
+
+	// Gets the challenges in all servers that ended before the given time and haven't
+	// had their winners announced
+	GetUnannounced(before time.Time) ([]*Challenge, error)
+
+	// Submits a score to the challenge. If the score was already submitted, returns
+	// false, nil
+	AddEntry(challengeID uint, scoreKey string) (bool, error)
+
+	// Gets each user's best score submitted to the challenge, ordered by the
+	// challenge's scoring rule (best first)
+	GetStandings(challenge *Challenge) ([]*Score, error)
+}
+
+// The rules for ranking the scores in a challenge
+const (
+	ChallengeBestAccuracy = "acc" // The most accurate score wins
+	ChallengeBestSSR      = "ssr" // The highest rated score wins
+)
+
+type Challenge struct {
+	BaseModel
+	ServerID    string          `db:"server_id"`  // Discord server that the challenge is run in
+	ChannelID   string          `db:"channel_id"` // Channel to announce the winners in
+	SongID      int             `db:"song_id"`    // The etterna ID of the song
+	Rates       pq.Float64Array `db:"rates"`      // Rates that scores must be played at. Empty for any rate
+	Scoring     string          `db:"scoring"`
+	StartsAt    time.Time       `db:"starts_at"`
+	EndsAt      time.Time       `db:"ends_at"`
+	AnnouncedAt *time.Time      `db:"announced_at"`
+}
+
+// AllowsRate checks if scores played at the rate can be submitted to the challenge
+func (c *Challenge) AllowsRate(rate float64) bool {
+	if len(c.Rates) == 0 {
+		return true
+	}
+
+	for _, r := range c.Rates {
+		// Rates are saved with 2 decimal places
+		if math.Round(r*100) == math.Round(rate*100) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,20 @@
+package model
+
+import "database/sql"
+
+type DiscordChannelServicer interface {
+	// Gets the channel with the given discord channel ID
+	Get(channelID string) (*DiscordChannel, error)
+
+	// Updates/creates the channel
+	Save(channel *DiscordChannel) error
+}
+
+// DiscordChannel holds the state the bot keeps for a single channel. Threads have their
+// own channel IDs so they are tracked separately from their parent channel
+type DiscordChannel struct {
+	BaseModel
+	ServerID   string        `db:"server_id"`    // Discord server ID
+	ChannelID  string        `db:"channel_id"`   // Discord channel ID
+	LastSongID sql.NullInt64 `db:"last_song_id"` // The last song posted in the channel
+}
@@ -1,16 +1,41 @@
 package model
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
 
 type DiscordServerServicer interface {
 	Get(serverID string) (*DiscordServer, error)
 	Save(server *DiscordServer) error
+
+	// Gets all of the servers that have a digest scheduled
+	GetWithDigest() ([]*DiscordServer, error)
 }
 
+// How often a server's digest is posted
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
 type DiscordServer struct {
 	BaseModel
 	CommandPrefix  string         `db:"command_prefix"`   // Prefix for using bot commands
 	ServerID       string         `db:"server_id"`        // Discord server ID
 	ScoreChannelID sql.NullString `db:"score_channel_id"` // The channel to post recent plays in
-	LastSongID     sql.NullInt64  `db:"last_song_id"`     // The last song posted by the bot
+	LastSongID     sql.NullInt64  `db:"last_song_id"`     // The last recent play posted by the tracker
+	RankMilestones pq.Int64Array  `db:"rank_milestones"`  // Global ranks that are called out when a user reaches them
+
+	DigestFrequency string         `db:"digest_frequency"`  // How often to post the digest (off, daily or weekly)
+	DigestChannelID sql.NullString `db:"digest_channel_id"` // The channel to post the digest in, if not the scores channel
+	DigestSections  pq.StringArray `db:"digest_sections"`   // Which sections to include in the digest
+	LastDigestAt    *time.Time     `db:"last_digest_at"`
+
+	NicknameTemplate sql.NullString `db:"nickname_template"` // Template for registered members' nicknames, or null if disabled
+
+	RequireVerification bool `db:"require_verification"` // Whether users must prove they own an etterna account to register
 }
@@ -3,6 +3,8 @@ package model
 import (
 	"database/sql"
 	"time"
+
+	"github.com/Kangaroux/etternabot/etterna"
 )
 
 type EtternaUserServicer interface {
@@ -15,6 +17,12 @@ type EtternaUserServicer interface {
 	// Gets the (cached) etterna user with a given username
 	GetUsername(username string) (*EtternaUser, error)
 
+	// Gets the (cached) etterna user with a given ID
+	GetID(userID uint) (*EtternaUser, error)
+
+	// Gets all etterna users that are registered in a given server
+	GetRegisteredUsers(serverID string) ([]*EtternaUser, error)
+
 	// Gets all etterna users that are registered as well as the discord server that
 	// each user is registered in. Used for tracking recent plays
 	GetRegisteredUsersForRecentPlays() ([]*RegisteredUserServers, error)
@@ -22,11 +30,45 @@ type EtternaUserServicer interface {
 	// Updates/creates the (cached) etterna user
 	Save(user *EtternaUser) error
 
-	// Registers a discord user with an etterna user for a particular discord server
+	// Gets the servers that the etterna user is registered in, along with the discord
+	// user they're registered to in each server
+	GetRegistrations(username string) ([]*Registration, error)
+
+	// Gets everyone who is registered in the server
+	GetServerRegistrations(serverID string) ([]*Registration, error)
+
+	// Registers a discord user with an etterna user for a particular discord server,
+	// overriding their global link in that server
 	Register(username, serverID, discordID string) (bool, error)
 
-	// Unregisters the discord user from any etterna users for a particular discord server
+	// Unregisters the discord user from any etterna users for a particular discord
+	// server. If they have a global link, they are opted out of it in the server
 	Unregister(serverID, discordID string) (bool, error)
+
+	// Gets the discord user's global link
+	GetLink(discordID string) (*Link, error)
+
+	// Links a discord user with an etterna user in every server they're in, replacing
+	// their existing link
+	Link(link *Link) (bool, error)
+
+	// Removes the discord user's global link along with their per-server registrations
+	// and opt outs
+	Unlink(discordID string) (bool, error)
+
+	// Records that the discord users are in the server. Users without a global link
+	// are ignored
+	AddMembers(serverID string, discordIDs []string) error
+
+	// Records that the discord user left the server, removing their registration there.
+	// Returns whether they were registered in the server
+	RemoveMember(serverID, discordID string) (bool, error)
+
+	// Gets the user's rating snapshots that were recorded within the given time range
+	GetRatingHistory(userID uint, start, end time.Time) ([]*RatingSnapshot, error)
+
+	// Gets the user's most recent rating snapshot at the given time
+	GetRatingAt(userID uint, t time.Time) (*RatingSnapshot, error)
 }
 
 type EtternaUser struct {
@@ -34,6 +76,7 @@ type EtternaUser struct {
 	Username            string         `db:"username"`
 	EtternaID           int            `db:"etterna_id"`
 	Avatar              string         `db:"avatar"`
+	CountryCode         string         `db:"country_code"` // Two letter country code, or empty if the user hasn't set one
 	LastRecentScoreKey  sql.NullString `db:"last_recent_score_key"`
 	LastRecentScoreDate *time.Time     `db:"last_recent_score_date"`
 	MSDOverall          float64        `db:"msd_overall"`
@@ -54,7 +97,104 @@ type EtternaUser struct {
 	RankTechnical       int            `db:"rank_technical"`
 }
 
+// MSD returns the cached ratings of the user
+func (u *EtternaUser) MSD() etterna.MSD {
+	return etterna.MSD{
+		Overall:    u.MSDOverall,
+		Stream:     u.MSDStream,
+		Jumpstream: u.MSDJumpstream,
+		Handstream: u.MSDHandstream,
+		Stamina:    u.MSDStamina,
+		JackSpeed:  u.MSDJackSpeed,
+		Chordjack:  u.MSDChordjack,
+		Technical:  u.MSDTechnical,
+	}
+}
+
+// Rank returns the cached global ranks of the user
+func (u *EtternaUser) Rank() etterna.Rank {
+	return etterna.Rank{
+		Overall:    u.RankOverall,
+		Stream:     u.RankStream,
+		Jumpstream: u.RankJumpstream,
+		Handstream: u.RankHandstream,
+		Stamina:    u.RankStamina,
+		JackSpeed:  u.RankJackSpeed,
+		Chordjack:  u.RankChordjack,
+		Technical:  u.RankTechnical,
+	}
+}
+
+// Registration links a discord user to an etterna user in a discord server, either
+// through their global link or a per-server registration
+type Registration struct {
+	ServerID      string `db:"server_id"`
+	Username      string `db:"username"`
+	DiscordUserID string `db:"discord_user_id"`
+}
+
+// Link links a discord user to an etterna user in every server that they're in, unless
+// they registered as someone else or opted out in the server
+type Link struct {
+	BaseModel
+	DiscordUserID string `db:"discord_user_id"`
+	Username      string `db:"username"`
+	Verified      bool   `db:"verified"` // Whether the discord user proved they own the account
+}
+
 type RegisteredUserServers struct {
 	User    EtternaUser
 	Servers []DiscordServer
 }
+
+// RatingSnapshot is a user's ratings and ranks at a point in time. A snapshot is
+// recorded each time the user is saved with a different rating or rank
+type RatingSnapshot struct {
+	ID             uint      `db:"id"`
+	CreatedAt      time.Time `db:"created_at"`
+	UserID         uint      `db:"user_id"`
+	MSDOverall     float64   `db:"msd_overall"`
+	MSDStream      float64   `db:"msd_stream"`
+	MSDJumpstream  float64   `db:"msd_jumpstream"`
+	MSDHandstream  float64   `db:"msd_handstream"`
+	MSDStamina     float64   `db:"msd_stamina"`
+	MSDJackSpeed   float64   `db:"msd_jackspeed"`
+	MSDChordjack   float64   `db:"msd_chordjack"`
+	MSDTechnical   float64   `db:"msd_technical"`
+	RankOverall    int       `db:"rank_overall"`
+	RankStream     int       `db:"rank_stream"`
+	RankJumpstream int       `db:"rank_jumpstream"`
+	RankHandstream int       `db:"rank_handstream"`
+	RankStamina    int       `db:"rank_stamina"`
+	RankJackSpeed  int       `db:"rank_jackspeed"`
+	RankChordjack  int       `db:"rank_chordjack"`
+	RankTechnical  int       `db:"rank_technical"`
+}
+
+// MSD returns the ratings of the snapshot
+func (r *RatingSnapshot) MSD() etterna.MSD {
+	return etterna.MSD{
+		Overall:    r.MSDOverall,
+		Stream:     r.MSDStream,
+		Jumpstream: r.MSDJumpstream,
+		Handstream: r.MSDHandstream,
+		Stamina:    r.MSDStamina,
+		JackSpeed:  r.MSDJackSpeed,
+		Chordjack:  r.MSDChordjack,
+		Technical:  r.MSDTechnical,
+	}
+}
+
+// Rank returns the global ranks of the snapshot
+func (r *RatingSnapshot) Rank() etterna.Rank {
+	return etterna.Rank{
+		Overall:    r.RankOverall,
+		Stream:     r.RankStream,
+		Jumpstream: r.RankJumpstream,
+		Handstream: r.RankHandstream,
+		Stamina:    r.RankStamina,
+		JackSpeed:  r.RankJackSpeed,
+		Chordjack:  r.RankChordjack,
+		Technical:  r.RankTechnical,
+	}
+}
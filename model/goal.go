@@ -0,0 +1,40 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Kangaroux/etternabot/etterna"
+)
+
+type GoalServicer interface {
+	// Updates/creates the goal
+	Save(goal *Goal) error
+
+	// Deletes the goal
+	Delete(goalID uint) error
+
+	// Gets the user's goals that haven't been completed, oldest first
+	GetOpen(userID uint) ([]*Goal, error)
+
+	// Gets the user's most recently completed goals
+	GetCompleted(userID uint, limit int) ([]*Goal, error)
+}
+
+// The kinds of goals a user can set
+const (
+	GoalRating = "rating" // Reach a rating in a skillset
+	GoalScore  = "score"  // Get an accuracy on a song at a rate
+	GoalRank   = "rank"   // Reach a global rank in a skillset
+)
+
+type Goal struct {
+	BaseModel
+	UserID      uint             `db:"user_id"` // The etterna user who set the goal
+	Kind        string           `db:"kind"`
+	Skillset    etterna.Skillset `db:"skillset"` // The skillset of a rating or rank goal
+	Target      float64          `db:"target"`   // The rating, accuracy or rank to reach
+	SongID      sql.NullInt64    `db:"song_id"`  // The etterna ID of the song of a score goal
+	Rate        float64          `db:"rate"`     // The min rate of a score goal
+	CompletedAt *time.Time       `db:"completed_at"`
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+type MatchServicer interface {
+	// Gets the match with the given ID
+	Get(matchID uint) (*Match, error)
+
+	// Gets the match in the channel that hasn't finished yet
+	GetActive(channelID string) (*Match, error)
+
+	// Gets the matches in all servers that are waiting on scores for a picked map
+	GetPlaying() ([]*Match, error)
+
+	// Updates/creates the match
+	Save(match *Match) error
+
+	// Deletes the match and its maps
+	Delete(matchID uint) error
+
+	// Gets the maps in the match's pool in the order they were added
+	GetMaps(matchID uint) ([]*MatchMap, error)
+
+	// Updates/creates the map
+	SaveMap(m *MatchMap) error
+
+	// Removes the map from the pool
+	DeleteMap(mapID uint) error
+}
+
+// The stages of a match
+const (
+	MatchBanning  = "banning"  // Players take turns banning maps from the pool
+	MatchPicking  = "picking"  // Waiting on a player to pick the next map
+	MatchPlaying  = "playing"  // Waiting on both players to play the picked map
+	MatchFinished = "finished" // One of the players won
+)
+
+// The states of a map in a match's pool
+const (
+	MapPool   = "pool"   // Can still be banned or picked
+	MapBanned = "banned" // Was banned by a player
+	MapPicked = "picked" // Was picked by a player, and is either being played or was played
+)
+
+type Match struct {
+	BaseModel
+	ServerID  string         `db:"server_id"`
+	ChannelID string         `db:"channel_id"` // Channel that the match is run in
+	MessageID sql.NullString `db:"message_id"` // The message with the match embed, which is edited as the match goes on
+	Player1ID uint           `db:"player1_id"` // The etterna users who are playing
+	Player2ID uint           `db:"player2_id"`
+	BestOf    int            `db:"best_of"`
+	Bans      int            `db:"bans"` // Number of maps each player bans
+	Status    string         `db:"status"`
+	TurnID    uint           `db:"turn_id"` // The player who bans or picks next
+}
+
+// Opponent returns the ID of the other player in the match
+func (m *Match) Opponent(userID uint) uint {
+	if userID == m.Player1ID {
+		return m.Player2ID
+	}
+
+	return m.Player1ID
+}
+
+type MatchMap struct {
+	BaseModel
+	MatchID         uint            `db:"match_id"`
+	SongID          int             `db:"song_id"` // The etterna ID of the song
+	Rate            float64         `db:"rate"`
+	Status          string          `db:"status"`
+	ChosenBy        sql.NullInt64   `db:"chosen_by"` // The player who banned or picked the map
+	PickedAt        *time.Time      `db:"picked_at"`
+	Player1LastKey  sql.NullString  `db:"player1_last_key"` // Each player's latest score on the map when it was picked, so
+	Player2LastKey  sql.NullString  `db:"player2_last_key"` // only scores played after the pick are counted
+	Player1Accuracy sql.NullFloat64 `db:"player1_accuracy"`
+	Player2Accuracy sql.NullFloat64 `db:"player2_accuracy"`
+	WinnerID        sql.NullInt64   `db:"winner_id"`
+}
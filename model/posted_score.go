@@ -0,0 +1,19 @@
+package model
+
+type PostedScoreServicer interface {
+	// Gets the score that was posted in the message with the given ID
+	Get(messageID string) (*PostedScore, error)
+
+	// Creates the posted score
+	Save(posted *PostedScore) error
+}
+
+// PostedScore records which score a message posted by the bot is for
+type PostedScore struct {
+	BaseModel
+	MessageID string  `db:"message_id"` // Discord message ID
+	ChannelID string  `db:"channel_id"` // Discord channel ID
+	SongID    int     `db:"song_id"`    // The etterna ID of the song
+	ScoreKey  string  `db:"score_key"`
+	Rate      float64 `db:"rate"`
+}
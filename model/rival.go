@@ -0,0 +1,30 @@
+package model
+
+type RivalServicer interface {
+	// Adds a rival for the user. The discord user is who gets notified when the rival
+	// overtakes the user. Returns false if the rival was already added
+	Add(userID, rivalID uint, discordID string) (bool, error)
+
+	// Removes a rival from the user. Returns false if they weren't a rival
+	Remove(userID, rivalID uint) (bool, error)
+
+	// Gets the (cached) etterna users that the user has added as rivals
+	GetRivals(userID uint) ([]*EtternaUser, error)
+
+	// Gets the users who have added the given user as a rival
+	GetFollowers(rivalID uint) ([]*RivalFollower, error)
+}
+
+type Rival struct {
+	BaseModel
+	UserID        uint   `db:"user_id"`         // The etterna user who added the rival
+	RivalID       uint   `db:"rival_id"`        // The etterna user who is the rival
+	DiscordUserID string `db:"discord_user_id"` // The discord user to notify
+}
+
+// RivalFollower is a user who has added someone as a rival, along with the discord
+// user to notify
+type RivalFollower struct {
+	EtternaUser
+	DiscordUserID string `db:"discord_user_id"`
+}
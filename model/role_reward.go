@@ -0,0 +1,25 @@
+package model
+
+import "github.com/Kangaroux/etternabot/etterna"
+
+type RoleRewardServicer interface {
+	// Updates/creates the server's reward for the role
+	Save(reward *RoleReward) error
+
+	// Removes the server's reward for the role. If there wasn't one, returns false, nil
+	Delete(serverID, roleID string) (bool, error)
+
+	// Gets the server's role rewards
+	GetAll(serverID string) ([]*RoleReward, error)
+}
+
+// RoleReward is a discord role that players are given while they have at least a
+// rating or are within a global rank in a skillset
+type RoleReward struct {
+	BaseModel
+	ServerID  string           `db:"server_id"`
+	RoleID    string           `db:"role_id"`
+	Kind      string           `db:"kind"` // GoalRating or GoalRank
+	Skillset  etterna.Skillset `db:"skillset"`
+	Threshold float64          `db:"threshold"` // The min rating or max rank
+}
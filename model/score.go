@@ -0,0 +1,104 @@
+package model
+
+import (
+	"time"
+
+	"github.com/Kangaroux/etternabot/etterna"
+)
+
+type ScoreServicer interface {
+	// Gets the score with the given score key
+	Get(scoreKey string) (*Score, error)
+
+	// Updates/creates the score
+	Save(score *Score) error
+
+	// Creates the score from a user's score list, which doesn't include the details of
+	// the score (max combo, mods, etc.). If the score was already saved, its details
+	// are left alone
+	SaveSummary(score *Score) error
+
+	// Counts the user's tracked, valid scores with at least the given accuracy
+	CountTracked(userID uint, minAccuracy float64) (int, error)
+
+	// Gets the keys of all of the saved scores played by the user
+	GetKeys(userID uint) ([]string, error)
+
+	// Gets all of the user's tracked scores, oldest first
+	GetTrackedHistory(userID uint) ([]*Score, error)
+
+	// Gets the user's best score (by accuracy) on a song at a rate
+	GetBestOnSong(userID uint, songID int, rate float64) (*Score, error)
+
+	// Gets the user's best score (by accuracy) on each song at each rate
+	GetBestPerSongRate(userID uint) ([]*Score, error)
+
+	// Estimates the rating of each song at each rate in a skillset from other users'
+	// scores with at least the given accuracy. Only songs that are cached and have an
+	// estimated rating within the range are returned
+	GetChartRatings(skillset etterna.Skillset, minAccuracy, minRating, maxRating float64, excludeUserID uint) ([]*ChartRating, error)
+
+	// Gets the valid scores played within the time range by users registered in the server
+	GetServerScores(serverID string, start, end time.Time) ([]*Score, error)
+
+	// Gets the scores played within the time range by users registered in the server
+	// that beat the user's previous best on the song at the same rate
+	GetServerPersonalBests(serverID string, start, end time.Time) ([]*Score, error)
+
+	// Gets when the user's score list was last synced from EO, or nil if it never was
+	GetLastSync(userID uint) (*time.Time, error)
+
+	// Records when the user's score list was synced from EO
+	SetLastSync(userID uint, t time.Time) error
+}
+
+type Score struct {
+	BaseModel
+	ScoreKey      string    `db:"score_key"`
+	UserID        uint      `db:"user_id"` // The etterna user who played the score
+	SongID        int       `db:"song_id"` // The etterna ID of the song
+	Rate          float64   `db:"rate"`
+	Accuracy      float64   `db:"accuracy"`
+	Marvelous     int       `db:"marvelous"`
+	Perfect       int       `db:"perfect"`
+	Great         int       `db:"great"`
+	Good          int       `db:"good"`
+	Bad           int       `db:"bad"`
+	Miss          int       `db:"miss"`
+	MaxCombo      int       `db:"max_combo"`
+	MinesHit      int       `db:"mines_hit"`
+	MSDOverall    float64   `db:"msd_overall"`
+	MSDStream     float64   `db:"msd_stream"`
+	MSDJumpstream float64   `db:"msd_jumpstream"`
+	MSDHandstream float64   `db:"msd_handstream"`
+	MSDStamina    float64   `db:"msd_stamina"`
+	MSDJackSpeed  float64   `db:"msd_jackspeed"`
+	MSDChordjack  float64   `db:"msd_chordjack"`
+	MSDTechnical  float64   `db:"msd_technical"`
+	Nerfed        float64   `db:"nerf"`
+	Mods          string    `db:"mods"`
+	Valid         bool      `db:"valid"`
+	PlayedAt      time.Time `db:"played_at"`
+	Tracked       bool      `db:"tracked"` // Whether the score was seen by the recent plays tracker
+}
+
+// ChartRating is the estimated rating of a song at a rate in one skillset
+type ChartRating struct {
+	SongID int     `db:"song_id"`
+	Rate   float64 `db:"rate"`
+	Rating float64 `db:"rating"`
+}
+
+// MSD returns the skillset ratings of the score
+func (s *Score) MSD() etterna.MSD {
+	return etterna.MSD{
+		Overall:    s.MSDOverall,
+		Stream:     s.MSDStream,
+		Jumpstream: s.MSDJumpstream,
+		Handstream: s.MSDHandstream,
+		Stamina:    s.MSDStamina,
+		JackSpeed:  s.MSDJackSpeed,
+		Chordjack:  s.MSDChordjack,
+		Technical:  s.MSDTechnical,
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"time"
+
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type AchievementService struct {
+	db *sqlx.DB
+}
+
+// NewAchievementService returns a service for managing unlocked achievements
+func NewAchievementService(db *sqlx.DB) AchievementService {
+	return AchievementService{db: db}
+}
+
+// Unlock unlocks the achievement for the user. If the user already unlocked the
+// achievement, returns false, nil
+func (s AchievementService) Unlock(userID uint, achievementID, scoreKey string) (bool, error) {
+	now := time.Now().UTC()
+
+	query := `
+		INSERT INTO "achievements" (
+			created_at,
+			updated_at,
+			user_id,
+			achievement,
+			score_key
+		)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	if _, err := s.db.Exec(query, now, now, userID, achievementID, scoreKey); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code.Name() == "unique_violation" {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetUnlocked returns the achievements the user has unlocked, oldest first
+func (s AchievementService) GetUnlocked(userID uint) ([]*model.Achievement, error) {
+	var achievements []*model.Achievement
+
+	query := `
+		SELECT *
+		FROM "achievements"
+		WHERE user_id=$1
+		ORDER BY created_at`
+
+	if err := s.db.Select(&achievements, query, userID); err != nil {
+		return nil, err
+	}
+
+	return achievements, nil
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type ChallengeService struct {
+	db *sqlx.DB
+}
+
+// NewChallengeService returns a service for managing server challenges and their entries
+func NewChallengeService(db *sqlx.DB) ChallengeService {
+	return ChallengeService{db: db}
+}
+
+// Get returns the challenge with the given ID, or nil if it doesn't exist
+func (s ChallengeService) Get(challengeID uint) (*model.Challenge, error) {
+	challenge := &model.Challenge{}
+
+	if err := s.db.Get(challenge, `SELECT * FROM "challenges" WHERE id=$1`, challengeID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// Save creates the challenge, or updates when its winners were announced
+func (s ChallengeService) Save(challenge *model.Challenge) error {
+	var err error
+
+	now := time.Now().UTC()
+	challenge.UpdatedAt = now
+
+	if challenge.ID == 0 {
+		challenge.CreatedAt = now
+		q := `INSERT INTO "challenges" (
+			created_at,
+			updated_at,
+			server_id,
+			channel_id,
+			song_id,
+			rates,
+			scoring,
+			starts_at,
+			ends_at,
+			announced_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`
+
+		err = s.db.Get(&challenge.ID, q,
+			challenge.CreatedAt,
+			challenge.UpdatedAt,
+			challenge.ServerID,
+			challenge.ChannelID,
+			challenge.SongID,
+			challenge.Rates,
+			challenge.Scoring,
+			challenge.StartsAt.UTC(),
+			challenge.EndsAt.UTC(),
+			challenge.AnnouncedAt,
+		)
+	} else {
+		q := `UPDATE "challenges" SET
+			updated_at=$2,
+			announced_at=$3
+		WHERE id=$1`
+
+		_, err = s.db.Exec(q,
+			challenge.ID,
+			challenge.UpdatedAt,
+			challenge.AnnouncedAt,
+		)
+	}
+
+	return err
+}
+
+// Delete deletes the challenge along with its entries
+func (s ChallengeService) Delete(challengeID uint) error {
+	_, err := s.db.Exec(`DELETE FROM "challenges" WHERE id=$1`, challengeID)
+
+	return err
+}
+
+// GetUpcoming returns the server's challenges that are running or haven't started yet,
+// ordered by when they end
+func (s ChallengeService) GetUpcoming(serverID string) ([]*model.Challenge, error) {
+	var challenges []*model.Challenge
+
+	query := `
+		SELECT *
+		FROM "challenges"
+		WHERE server_id=$1 AND ends_at > $2
+		ORDER BY ends_at, id`
+
+	if err := s.db.Select(&challenges, query, serverID, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	return challenges, nil
+}
+
+// GetUnannounced returns the challenges that ended before the given time and haven't
+// had their winners announced yet
+func (s ChallengeService) GetUnannounced(before time.Time) ([]*model.Challenge, error) {
+	var challenges []*model.Challenge
+
+	query := `
+		SELECT *
+		FROM "challenges"
+		WHERE ends_at <= $1 AND announced_at IS NULL
+		ORDER BY ends_at, id`
+
+	if err := s.db.Select(&challenges, query, before.UTC()); err != nil {
+		return nil, err
+	}
+
+	return challenges, nil
+}
+
+// AddEntry submits the score to the challenge. The score must already be saved. If the
+// score was already submitted, returns false, nil
+func (s ChallengeService) AddEntry(challengeID uint, scoreKey string) (bool, error) {
+	query := `
+		INSERT INTO "challenge_entries" (
+			created_at,
+			challenge_id,
+			score_key
+		)
+		VALUES ($1, $2, $3)
+	`
+
+	if _, err := s.db.Exec(query, time.Now().UTC(), challengeID, scoreKey); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code.Name() == "unique_violation" {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetStandings returns each user's best score that was submitted to the challenge,
+// best first. Ties go to whoever played the score first
+func (s ChallengeService) GetStandings(challenge *model.Challenge) ([]*model.Score, error) {
+	var scores []*model.Score
+
+	column := "accuracy"
+
+	if challenge.Scoring == model.ChallengeBestSSR {
+		column = "msd_overall"
+	}
+
+	query := `
+		SELECT * FROM (
+			SELECT DISTINCT ON (sc.user_id) sc.*
+			FROM "challenge_entries" e
+			INNER JOIN "scores" sc ON sc.score_key=e.score_key
+			WHERE e.challenge_id=$1
+			ORDER BY sc.user_id, sc.` + column + ` DESC, sc.played_at
+		) best
+		ORDER BY ` + column + ` DESC, played_at`
+
+	if err := s.db.Select(&scores, query, challenge.ID); err != nil {
+		return nil, err
+	}
+
+	return scores, nil
+}
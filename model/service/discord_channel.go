@@ -0,0 +1,64 @@
+package service
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type DiscordChannelService struct {
+	db *sqlx.DB
+}
+
+// NewDiscordChannelService returns a service for managing per-channel state
+func NewDiscordChannelService(db *sqlx.DB) DiscordChannelService {
+	return DiscordChannelService{db: db}
+}
+
+// Get returns the channel with the given ID, or nil if nothing has been saved for it yet
+func (s DiscordChannelService) Get(channelID string) (*model.DiscordChannel, error) {
+	channel := &model.DiscordChannel{}
+
+	if err := s.db.Get(channel, `SELECT * FROM "discord_channels" WHERE channel_id=$1`, channelID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return channel, nil
+}
+
+// Save creates the channel, or updates it if a channel with the same ID already exists
+func (s DiscordChannelService) Save(channel *model.DiscordChannel) error {
+	now := time.Now().UTC()
+	channel.UpdatedAt = now
+
+	if channel.CreatedAt.IsZero() {
+		channel.CreatedAt = now
+	}
+
+	q := `INSERT INTO "discord_channels" (
+		created_at,
+		updated_at,
+		server_id,
+		channel_id,
+		last_song_id
+	)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (channel_id) DO UPDATE SET
+		updated_at=EXCLUDED.updated_at,
+		last_song_id=EXCLUDED.last_song_id
+	RETURNING id`
+
+	return s.db.Get(&channel.ID, q,
+		channel.CreatedAt,
+		channel.UpdatedAt,
+		channel.ServerID,
+		channel.ChannelID,
+		channel.LastSongID,
+	)
+}
@@ -44,9 +44,16 @@ func (s DiscordServerService) Save(server *model.DiscordServer) error {
 			command_prefix,
 			server_id,
 			score_channel_id,
-			last_song_id
+			last_song_id,
+			rank_milestones,
+			digest_frequency,
+			digest_channel_id,
+			digest_sections,
+			last_digest_at,
+			nickname_template,
+			require_verification
 		)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		RETURNING id`
 
 		err = s.db.Get(&server.ID, q,
@@ -56,13 +63,27 @@ func (s DiscordServerService) Save(server *model.DiscordServer) error {
 			server.ServerID,
 			server.ScoreChannelID,
 			server.LastSongID,
+			server.RankMilestones,
+			server.DigestFrequency,
+			server.DigestChannelID,
+			server.DigestSections,
+			server.LastDigestAt,
+			server.NicknameTemplate,
+			server.RequireVerification,
 		)
 	} else {
 		q := `UPDATE "discord_servers" SET
 			updated_at=$2,
 			command_prefix=$3,
 			score_channel_id=$4,
-			last_song_id=$5
+			last_song_id=$5,
+			rank_milestones=$6,
+			digest_frequency=$7,
+			digest_channel_id=$8,
+			digest_sections=$9,
+			last_digest_at=$10,
+			nickname_template=$11,
+			require_verification=$12
 		WHERE id=$1`
 
 		_, err = s.db.Exec(q,
@@ -71,8 +92,28 @@ func (s DiscordServerService) Save(server *model.DiscordServer) error {
 			server.CommandPrefix,
 			server.ScoreChannelID,
 			server.LastSongID,
+			server.RankMilestones,
+			server.DigestFrequency,
+			server.DigestChannelID,
+			server.DigestSections,
+			server.LastDigestAt,
+			server.NicknameTemplate,
+			server.RequireVerification,
 		)
 	}
 
 	return err
 }
+
+// GetWithDigest returns all of the servers that have a digest scheduled
+func (s DiscordServerService) GetWithDigest() ([]*model.DiscordServer, error) {
+	var servers []*model.DiscordServer
+
+	query := `SELECT * FROM "discord_servers" WHERE digest_frequency <> $1`
+
+	if err := s.db.Select(&servers, query, model.DigestOff); err != nil {
+		return nil, err
+	}
+
+	return servers, nil
+}
@@ -25,7 +25,7 @@ func (s EtternaUserService) GetRegisteredDiscordUserID(serverID, username string
 	var discordID string
 
 	query := `
-		SELECT discord_user_id FROM "users_discord_servers"
+		SELECT discord_user_id FROM "registrations"
 		WHERE server_id=$1 AND lower(username)=lower($2)
 	`
 
@@ -45,9 +45,9 @@ func (s EtternaUserService) GetRegisteredDiscordUserID(serverID, username string
 func (s EtternaUserService) GetRegisteredUser(serverID, discordID string) (*model.EtternaUser, error) {
 	user := &model.EtternaUser{}
 	query := `
-		SELECT u.* FROM "users_discord_servers" uds
-		INNER JOIN "etterna_users" u ON u.username=uds.username
-		WHERE uds.discord_user_id=$1 AND uds.server_id=$2
+		SELECT u.* FROM "registrations" r
+		INNER JOIN "etterna_users" u ON u.username=r.username
+		WHERE r.discord_user_id=$1 AND r.server_id=$2
 	`
 
 	if err := s.db.Get(user, query, discordID, serverID); err != nil {
@@ -80,6 +80,39 @@ func (s EtternaUserService) GetUsername(username string) (*model.EtternaUser, er
 	return user, nil
 }
 
+// GetID returns the cached etterna user with the given ID, or nil if there isn't one
+func (s EtternaUserService) GetID(userID uint) (*model.EtternaUser, error) {
+	user := &model.EtternaUser{}
+
+	if err := s.db.Get(user, `SELECT * FROM "etterna_users" WHERE id=$1`, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetRegisteredUsers returns the (cached) etterna users that are registered in the
+// given discord server
+func (s EtternaUserService) GetRegisteredUsers(serverID string) ([]*model.EtternaUser, error) {
+	var users []*model.EtternaUser
+
+	query := `
+		SELECT u.* FROM "registrations" r
+		INNER JOIN "etterna_users" u ON u.username=r.username
+		WHERE r.server_id=$1
+	`
+
+	if err := s.db.Select(&users, query, serverID); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
 // GetRegisteredUsersForRecentPlays looks up all of the registered etterna users
 // that are in servers which have a scores channel set
 func (s EtternaUserService) GetRegisteredUsersForRecentPlays() ([]*model.RegisteredUserServers, error) {
@@ -95,6 +128,7 @@ func (s EtternaUserService) GetRegisteredUsersForRecentPlays() ([]*model.Registe
 			u.updated_at             "u.updated_at",
 			u.etterna_id             "u.etterna_id",
 			u.avatar                 "u.avatar",
+			u.country_code           "u.country_code",
 			u.username               "u.username",
 			u.last_recent_score_key  "u.last_recent_score_key",
 			u.last_recent_score_date "u.last_recent_score_date",
@@ -119,11 +153,19 @@ func (s EtternaUserService) GetRegisteredUsersForRecentPlays() ([]*model.Registe
 			s.updated_at             "s.updated_at",
 			s.command_prefix         "s.command_prefix",
 			s.server_id              "s.server_id",
-			s.score_channel_id       "s.score_channel_id"
+			s.score_channel_id       "s.score_channel_id",
+			s.last_song_id           "s.last_song_id",
+			s.rank_milestones        "s.rank_milestones",
+			s.digest_frequency       "s.digest_frequency",
+			s.digest_channel_id      "s.digest_channel_id",
+			s.digest_sections        "s.digest_sections",
+			s.last_digest_at         "s.last_digest_at",
+			s.nickname_template      "s.nickname_template",
+			s.require_verification   "s.require_verification"
 		FROM
 			etterna_users u
-		INNER JOIN users_discord_servers uds ON uds.username=u.username
-		INNER JOIN discord_servers s ON s.server_id=uds.server_id
+		INNER JOIN registrations r ON r.username=u.username
+		INNER JOIN discord_servers s ON s.server_id=r.server_id
 		WHERE
 			s.score_channel_id IS NOT NULL
 	`
@@ -169,6 +211,7 @@ func (s EtternaUserService) Save(user *model.EtternaUser) error {
 			username,
 			etterna_id,
 			avatar,
+			country_code,
 			last_recent_score_key,
 			last_recent_score_date,
 			msd_overall,
@@ -188,7 +231,7 @@ func (s EtternaUserService) Save(user *model.EtternaUser) error {
 			rank_chordjack,
 			rank_technical
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)
 		RETURNING id`
 
 		err = s.db.Get(&user.ID, q,
@@ -197,6 +240,7 @@ func (s EtternaUserService) Save(user *model.EtternaUser) error {
 			user.Username,
 			user.EtternaID,
 			user.Avatar,
+			user.CountryCode,
 			user.LastRecentScoreKey,
 			user.LastRecentScoreDate,
 			user.MSDOverall,
@@ -220,30 +264,32 @@ func (s EtternaUserService) Save(user *model.EtternaUser) error {
 		q := `UPDATE "etterna_users" SET
 			updated_at=$2,
 			avatar=$3,
-			last_recent_score_key=$4,
-			last_recent_score_date=$5,
-			msd_overall=$6,
-			msd_stream=$7,
-			msd_jumpstream=$8,
-			msd_handstream=$9,
-			msd_stamina=$10,
-			msd_jackspeed=$11,
-			msd_chordjack=$12,
-			msd_technical=$13,
-			rank_overall=$14,
-			rank_stream=$15,
-			rank_jumpstream=$16,
-			rank_handstream=$17,
-			rank_stamina=$18,
-			rank_jackspeed=$19,
-			rank_chordjack=$20,
-			rank_technical=$21
+			country_code=$4,
+			last_recent_score_key=$5,
+			last_recent_score_date=$6,
+			msd_overall=$7,
+			msd_stream=$8,
+			msd_jumpstream=$9,
+			msd_handstream=$10,
+			msd_stamina=$11,
+			msd_jackspeed=$12,
+			msd_chordjack=$13,
+			msd_technical=$14,
+			rank_overall=$15,
+			rank_stream=$16,
+			rank_jumpstream=$17,
+			rank_handstream=$18,
+			rank_stamina=$19,
+			rank_jackspeed=$20,
+			rank_chordjack=$21,
+			rank_technical=$22
 		WHERE lower(username)=lower($1)`
 
 		_, err = s.db.Exec(q,
 			user.Username,
 			user.UpdatedAt,
 			user.Avatar,
+			user.CountryCode,
 			user.LastRecentScoreKey,
 			user.LastRecentScoreDate,
 			user.MSDOverall,
@@ -270,13 +316,178 @@ func (s EtternaUserService) Save(user *model.EtternaUser) error {
 		return err
 	}
 
-	return nil
+	return s.saveRatingSnapshot(user)
+}
+
+// saveRatingSnapshot adds the user's current ratings and ranks to their rating
+// history, unless they are the same as the most recent snapshot
+func (s EtternaUserService) saveRatingSnapshot(user *model.EtternaUser) error {
+	if user.ID == 0 {
+		return nil
+	}
+
+	q := `INSERT INTO "rating_history" (
+		created_at,
+		user_id,
+		msd_overall,
+		msd_stream,
+		msd_jumpstream,
+		msd_handstream,
+		msd_stamina,
+		msd_jackspeed,
+		msd_chordjack,
+		msd_technical,
+		rank_overall,
+		rank_stream,
+		rank_jumpstream,
+		rank_handstream,
+		rank_stamina,
+		rank_jackspeed,
+		rank_chordjack,
+		rank_technical
+	)
+	SELECT $1, $2,
+		CAST($3 AS DECIMAL(4, 2)),
+		CAST($4 AS DECIMAL(4, 2)),
+		CAST($5 AS DECIMAL(4, 2)),
+		CAST($6 AS DECIMAL(4, 2)),
+		CAST($7 AS DECIMAL(4, 2)),
+		CAST($8 AS DECIMAL(4, 2)),
+		CAST($9 AS DECIMAL(4, 2)),
+		CAST($10 AS DECIMAL(4, 2)),
+		$11, $12, $13, $14, $15, $16, $17, $18
+	WHERE NOT EXISTS (
+		SELECT 1 FROM (
+			SELECT * FROM "rating_history"
+			WHERE user_id=$2
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		) h
+		WHERE
+			h.msd_overall=CAST($3 AS DECIMAL(4, 2)) AND
+			h.msd_stream=CAST($4 AS DECIMAL(4, 2)) AND
+			h.msd_jumpstream=CAST($5 AS DECIMAL(4, 2)) AND
+			h.msd_handstream=CAST($6 AS DECIMAL(4, 2)) AND
+			h.msd_stamina=CAST($7 AS DECIMAL(4, 2)) AND
+			h.msd_jackspeed=CAST($8 AS DECIMAL(4, 2)) AND
+			h.msd_chordjack=CAST($9 AS DECIMAL(4, 2)) AND
+			h.msd_technical=CAST($10 AS DECIMAL(4, 2)) AND
+			h.rank_overall=$11 AND
+			h.rank_stream=$12 AND
+			h.rank_jumpstream=$13 AND
+			h.rank_handstream=$14 AND
+			h.rank_stamina=$15 AND
+			h.rank_jackspeed=$16 AND
+			h.rank_chordjack=$17 AND
+			h.rank_technical=$18
+	)`
+
+	_, err := s.db.Exec(q,
+		user.UpdatedAt,
+		user.ID,
+		user.MSDOverall,
+		user.MSDStream,
+		user.MSDJumpstream,
+		user.MSDHandstream,
+		user.MSDStamina,
+		user.MSDJackSpeed,
+		user.MSDChordjack,
+		user.MSDTechnical,
+		user.RankOverall,
+		user.RankStream,
+		user.RankJumpstream,
+		user.RankHandstream,
+		user.RankStamina,
+		user.RankJackSpeed,
+		user.RankChordjack,
+		user.RankTechnical,
+	)
+
+	return err
 }
 
-// Register associates an etterna user with a discord user for a particular server.
-// For a given server, there needs to be a One-to-One relationship between etterna
-// users and discord users. If the constraint is violated, returns false, nil
+// GetRatingHistory returns the user's rating snapshots between start and end (inclusive),
+// oldest first
+func (s EtternaUserService) GetRatingHistory(userID uint, start, end time.Time) ([]*model.RatingSnapshot, error) {
+	var history []*model.RatingSnapshot
+
+	query := `
+		SELECT * FROM "rating_history"
+		WHERE user_id=$1 AND created_at BETWEEN $2 AND $3
+		ORDER BY created_at, id
+	`
+
+	if err := s.db.Select(&history, query, userID, start.UTC(), end.UTC()); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// GetRatingAt returns the user's ratings as they were at the given time. If there
+// is no history from before that time, returns nil
+func (s EtternaUserService) GetRatingAt(userID uint, t time.Time) (*model.RatingSnapshot, error) {
+	snapshot := &model.RatingSnapshot{}
+
+	query := `
+		SELECT * FROM "rating_history"
+		WHERE user_id=$1 AND created_at <= $2
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`
+
+	if err := s.db.Get(snapshot, query, userID, t.UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// GetRegistrations returns the servers that the etterna user is registered in, along
+// with the discord user they're registered to in each one
+func (s EtternaUserService) GetRegistrations(username string) ([]*model.Registration, error) {
+	var registrations []*model.Registration
+
+	query := `
+		SELECT * FROM "registrations"
+		WHERE lower(username)=lower($1)
+	`
+
+	if err := s.db.Select(&registrations, query, username); err != nil {
+		return nil, err
+	}
+
+	return registrations, nil
+}
+
+// GetServerRegistrations returns everyone who is registered in the server, ordered by
+// their etterna username
+func (s EtternaUserService) GetServerRegistrations(serverID string) ([]*model.Registration, error) {
+	var registrations []*model.Registration
+
+	query := `
+		SELECT * FROM "registrations"
+		WHERE server_id=$1
+		ORDER BY lower(username)
+	`
+
+	if err := s.db.Select(&registrations, query, serverID); err != nil {
+		return nil, err
+	}
+
+	return registrations, nil
+}
+
+// Register associates an etterna user with a discord user for a particular server,
+// overriding the discord user's global link in the server. For a given server, there
+// needs to be a One-to-One relationship between etterna users and discord users. If
+// the constraint is violated, returns false, nil
 func (s EtternaUserService) Register(username, serverID, discordID string) (bool, error) {
+	// Replaces the discord user's opt out, but not their existing registration
 	query := `
 		INSERT INTO "users_discord_servers" (
 			username,
@@ -284,9 +495,15 @@ func (s EtternaUserService) Register(username, serverID, discordID string) (bool
 			server_id
 		)
 		VALUES ($1, $2, $3)
+		ON CONFLICT (server_id, discord_user_id) DO UPDATE SET
+			username=EXCLUDED.username,
+			opted_out=false
+		WHERE users_discord_servers.opted_out
 	`
 
-	if _, err := s.db.Exec(query, username, discordID, serverID); err != nil {
+	result, err := s.db.Exec(query, username, discordID, serverID)
+
+	if err != nil {
 		if err, ok := err.(*pq.Error); ok && err.Code.Name() == "unique_violation" {
 			return false, nil
 		}
@@ -294,24 +511,223 @@ func (s EtternaUserService) Register(username, serverID, discordID string) (bool
 		return false, err
 	}
 
-	return true, nil
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
 }
 
 // Unregister deletes any association to etterna users for the given discord user
-// in the given server. If the user was registered, returns true, nil
+// in the given server. If the discord user has a global link, they are opted out of
+// it in the server. If the user was registered, returns true, nil
 func (s EtternaUserService) Unregister(serverID, discordID string) (bool, error) {
-	var err error
-	var result sql.Result
+	tx, err := s.db.Beginx()
+
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	var registered bool
+
+	query := `SELECT EXISTS (SELECT 1 FROM "registrations" WHERE server_id=$1 AND discord_user_id=$2)`
+
+	if err := tx.Get(&registered, query, serverID, discordID); err != nil {
+		return false, err
+	} else if !registered {
+		return false, nil
+	}
+
+	query = `DELETE FROM "users_discord_servers" WHERE server_id=$1 AND discord_user_id=$2`
+
+	if _, err := tx.Exec(query, serverID, discordID); err != nil {
+		return false, err
+	}
+
+	query = `
+		INSERT INTO "users_discord_servers" (server_id, discord_user_id, opted_out)
+		SELECT $1, $2, true
+		WHERE EXISTS (SELECT 1 FROM "discord_links" WHERE discord_user_id=$2)
+	`
+
+	if _, err := tx.Exec(query, serverID, discordID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetLink returns the discord user's global link, or nil if they don't have one
+func (s EtternaUserService) GetLink(discordID string) (*model.Link, error) {
+	link := &model.Link{}
+
+	if err := s.db.Get(link, `SELECT * FROM "discord_links" WHERE discord_user_id=$1`, discordID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return link, nil
+}
+
+// Link links the discord user with an etterna user in every server they're in,
+// replacing their existing link. Only one discord user can be linked to an etterna
+// user. A verified link replaces anyone else's unverified link to the etterna user,
+// along with their registrations as the etterna user in each server.
+// If the constraint is violated, returns false, nil
+func (s EtternaUserService) Link(link *model.Link) (bool, error) {
+	now := time.Now().UTC()
+	link.UpdatedAt = now
+
+	if link.ID == 0 {
+		link.CreatedAt = now
+	}
+
+	tx, err := s.db.Beginx()
+
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	if link.Verified {
+		// The other user's per-server registrations as the etterna user go with their link
+		query := `
+			DELETE FROM "users_discord_servers" uds
+			USING "discord_links" l
+			WHERE l.discord_user_id=uds.discord_user_id
+			AND lower(l.username)=lower($1) AND l.discord_user_id<>$2 AND NOT l.verified
+			AND lower(uds.username)=lower($1) AND NOT uds.opted_out`
+
+		if _, err := tx.Exec(query, link.Username, link.DiscordUserID); err != nil {
+			return false, err
+		}
+
+		query = `
+			DELETE FROM "discord_links"
+			WHERE lower(username)=lower($1) AND discord_user_id<>$2 AND NOT verified`
+
+		if _, err := tx.Exec(query, link.Username, link.DiscordUserID); err != nil {
+			return false, err
+		}
+	}
 
 	query := `
-		DELETE FROM "users_discord_servers"
-		WHERE server_id=$1 AND discord_user_id=$2
+		INSERT INTO "discord_links" (
+			created_at,
+			updated_at,
+			discord_user_id,
+			username,
+			verified
+		)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (discord_user_id) DO UPDATE SET
+			updated_at=EXCLUDED.updated_at,
+			username=EXCLUDED.username,
+			verified=EXCLUDED.verified
+		RETURNING id`
+
+	err = tx.Get(&link.ID, query,
+		link.CreatedAt,
+		link.UpdatedAt,
+		link.DiscordUserID,
+		link.Username,
+		link.Verified,
+	)
+
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code.Name() == "unique_violation" {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Unlink removes the discord user's global link, and their per-server registrations
+// and opt outs. If the user was linked or registered anywhere, returns true, nil
+func (s EtternaUserService) Unlink(discordID string) (bool, error) {
+	tx, err := s.db.Beginx()
+
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	// Memberships are removed along with the link
+	links, err := tx.Exec(`DELETE FROM "discord_links" WHERE discord_user_id=$1`, discordID)
+
+	if err != nil {
+		return false, err
+	}
+
+	registrations, err := tx.Exec(`DELETE FROM "users_discord_servers" WHERE discord_user_id=$1 AND NOT opted_out`, discordID)
+
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM "users_discord_servers" WHERE discord_user_id=$1`, discordID); err != nil {
+		return false, err
+	}
+
+	linked, _ := links.RowsAffected()
+	registered, _ := registrations.RowsAffected()
+
+	return linked+registered > 0, tx.Commit()
+}
+
+// AddMembers records that the discord users are in the server, so their global link
+// applies there. Users without a global link are ignored
+func (s EtternaUserService) AddMembers(serverID string, discordIDs []string) error {
+	query := `
+		INSERT INTO "discord_members" (server_id, discord_user_id)
+		SELECT $1, discord_user_id FROM "discord_links"
+		WHERE discord_user_id = ANY($2)
+		ON CONFLICT DO NOTHING
 	`
 
-	if result, err = s.db.Exec(query, serverID, discordID); err != nil {
+	_, err := s.db.Exec(query, serverID, pq.StringArray(discordIDs))
+
+	return err
+}
+
+// RemoveMember records that the discord user left the server. Their registration in
+// the server is removed, but their opt out is kept in case they come back. If the
+// user was registered, returns true, nil
+func (s EtternaUserService) RemoveMember(serverID, discordID string) (bool, error) {
+	tx, err := s.db.Beginx()
+
+	if err != nil {
 		return false, err
 	}
 
-	affected, _ := result.RowsAffected()
-	return affected > 0, nil
+	defer tx.Rollback()
+
+	var registered bool
+
+	query := `SELECT EXISTS (SELECT 1 FROM "registrations" WHERE server_id=$1 AND discord_user_id=$2)`
+
+	if err := tx.Get(&registered, query, serverID, discordID); err != nil {
+		return false, err
+	}
+
+	query = `DELETE FROM "discord_members" WHERE server_id=$1 AND discord_user_id=$2`
+
+	if _, err := tx.Exec(query, serverID, discordID); err != nil {
+		return false, err
+	}
+
+	query = `DELETE FROM "users_discord_servers" WHERE server_id=$1 AND discord_user_id=$2 AND NOT opted_out`
+
+	if _, err := tx.Exec(query, serverID, discordID); err != nil {
+		return false, err
+	}
+
+	return registered, tx.Commit()
 }
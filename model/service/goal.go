@@ -0,0 +1,109 @@
+package service
+
+import (
+	"time"
+
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type GoalService struct {
+	db *sqlx.DB
+}
+
+// NewGoalService returns a service for managing the goals of users
+func NewGoalService(db *sqlx.DB) GoalService {
+	return GoalService{db: db}
+}
+
+// Save creates the goal, or marks an existing goal as completed
+func (s GoalService) Save(goal *model.Goal) error {
+	var err error
+
+	now := time.Now().UTC()
+	goal.UpdatedAt = now
+
+	if goal.ID == 0 {
+		goal.CreatedAt = now
+		q := `INSERT INTO "goals" (
+			created_at,
+			updated_at,
+			user_id,
+			kind,
+			skillset,
+			target,
+			song_id,
+			rate,
+			completed_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`
+
+		err = s.db.Get(&goal.ID, q,
+			goal.CreatedAt,
+			goal.UpdatedAt,
+			goal.UserID,
+			goal.Kind,
+			goal.Skillset,
+			goal.Target,
+			goal.SongID,
+			goal.Rate,
+			goal.CompletedAt,
+		)
+	} else {
+		q := `UPDATE "goals" SET
+			updated_at=$2,
+			completed_at=$3
+		WHERE id=$1`
+
+		_, err = s.db.Exec(q,
+			goal.ID,
+			goal.UpdatedAt,
+			goal.CompletedAt,
+		)
+	}
+
+	return err
+}
+
+// Delete deletes the goal
+func (s GoalService) Delete(goalID uint) error {
+	_, err := s.db.Exec(`DELETE FROM "goals" WHERE id=$1`, goalID)
+
+	return err
+}
+
+// GetOpen returns the user's goals that haven't been completed yet, oldest first
+func (s GoalService) GetOpen(userID uint) ([]*model.Goal, error) {
+	var goals []*model.Goal
+
+	query := `
+		SELECT *
+		FROM "goals"
+		WHERE user_id=$1 AND completed_at IS NULL
+		ORDER BY created_at`
+
+	if err := s.db.Select(&goals, query, userID); err != nil {
+		return nil, err
+	}
+
+	return goals, nil
+}
+
+// GetCompleted returns up to limit of the user's completed goals, newest first
+func (s GoalService) GetCompleted(userID uint, limit int) ([]*model.Goal, error) {
+	var goals []*model.Goal
+
+	query := `
+		SELECT *
+		FROM "goals"
+		WHERE user_id=$1 AND completed_at IS NOT NULL
+		ORDER BY completed_at DESC
+		LIMIT $2`
+
+	if err := s.db.Select(&goals, query, userID, limit); err != nil {
+		return nil, err
+	}
+
+	return goals, nil
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type MatchService struct {
+	db *sqlx.DB
+}
+
+// NewMatchService returns a service for managing 1v1 matches and their map pools
+func NewMatchService(db *sqlx.DB) MatchService {
+	return MatchService{db: db}
+}
+
+// Get returns the match with the given ID, or nil if it doesn't exist
+func (s MatchService) Get(matchID uint) (*model.Match, error) {
+	match := &model.Match{}
+
+	if err := s.db.Get(match, `SELECT * FROM "matches" WHERE id=$1`, matchID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return match, nil
+}
+
+// GetActive returns the match in the channel that hasn't finished yet, or nil if
+// there isn't one
+func (s MatchService) GetActive(channelID string) (*model.Match, error) {
+	match := &model.Match{}
+
+	query := `
+		SELECT *
+		FROM "matches"
+		WHERE channel_id=$1 AND status <> $2
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	if err := s.db.Get(match, query, channelID, model.MatchFinished); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return match, nil
+}
+
+// GetPlaying returns the matches that are waiting on scores for a picked map
+func (s MatchService) GetPlaying() ([]*model.Match, error) {
+	var matches []*model.Match
+
+	if err := s.db.Select(&matches, `SELECT * FROM "matches" WHERE status=$1`, model.MatchPlaying); err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+// Save updates/creates the match
+func (s MatchService) Save(match *model.Match) error {
+	var err error
+
+	now := time.Now().UTC()
+	match.UpdatedAt = now
+
+	if match.ID == 0 {
+		match.CreatedAt = now
+		q := `INSERT INTO "matches" (
+			created_at,
+			updated_at,
+			server_id,
+			channel_id,
+			message_id,
+			player1_id,
+			player2_id,
+			best_of,
+			bans,
+			status,
+			turn_id
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id`
+
+		err = s.db.Get(&match.ID, q,
+			match.CreatedAt,
+			match.UpdatedAt,
+			match.ServerID,
+			match.ChannelID,
+			match.MessageID,
+			match.Player1ID,
+			match.Player2ID,
+			match.BestOf,
+			match.Bans,
+			match.Status,
+			match.TurnID,
+		)
+	} else {
+		q := `UPDATE "matches" SET
+			updated_at=$2,
+			message_id=$3,
+			status=$4,
+			turn_id=$5
+		WHERE id=$1`
+
+		_, err = s.db.Exec(q,
+			match.ID,
+			match.UpdatedAt,
+			match.MessageID,
+			match.Status,
+			match.TurnID,
+		)
+	}
+
+	return err
+}
+
+// Delete deletes the match along with its maps
+func (s MatchService) Delete(matchID uint) error {
+	_, err := s.db.Exec(`DELETE FROM "matches" WHERE id=$1`, matchID)
+
+	return err
+}
+
+// GetMaps returns the maps in the match's pool in the order they were added
+func (s MatchService) GetMaps(matchID uint) ([]*model.MatchMap, error) {
+	var maps []*model.MatchMap
+
+	if err := s.db.Select(&maps, `SELECT * FROM "match_maps" WHERE match_id=$1 ORDER BY id`, matchID); err != nil {
+		return nil, err
+	}
+
+	return maps, nil
+}
+
+// SaveMap updates/creates the map
+func (s MatchService) SaveMap(m *model.MatchMap) error {
+	var err error
+
+	now := time.Now().UTC()
+	m.UpdatedAt = now
+
+	if m.ID == 0 {
+		m.CreatedAt = now
+		q := `INSERT INTO "match_maps" (
+			created_at,
+			updated_at,
+			match_id,
+			song_id,
+			rate,
+			status
+		)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+		err = s.db.Get(&m.ID, q,
+			m.CreatedAt,
+			m.UpdatedAt,
+			m.MatchID,
+			m.SongID,
+			m.Rate,
+			m.Status,
+		)
+	} else {
+		q := `UPDATE "match_maps" SET
+			updated_at=$2,
+			status=$3,
+			chosen_by=$4,
+			picked_at=$5,
+			player1_last_key=$6,
+			player2_last_key=$7,
+			player1_accuracy=$8,
+			player2_accuracy=$9,
+			winner_id=$10
+		WHERE id=$1`
+
+		_, err = s.db.Exec(q,
+			m.ID,
+			m.UpdatedAt,
+			m.Status,
+			m.ChosenBy,
+			m.PickedAt,
+			m.Player1LastKey,
+			m.Player2LastKey,
+			m.Player1Accuracy,
+			m.Player2Accuracy,
+			m.WinnerID,
+		)
+	}
+
+	return err
+}
+
+// DeleteMap removes the map from the match's pool
+func (s MatchService) DeleteMap(mapID uint) error {
+	_, err := s.db.Exec(`DELETE FROM "match_maps" WHERE id=$1`, mapID)
+
+	return err
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type PostedScoreService struct {
+	db *sqlx.DB
+}
+
+// NewPostedScoreService returns a service for looking up which score a message is for
+func NewPostedScoreService(db *sqlx.DB) PostedScoreService {
+	return PostedScoreService{db: db}
+}
+
+// Get returns the score posted in the message, or nil if the message isn't a score
+func (s PostedScoreService) Get(messageID string) (*model.PostedScore, error) {
+	posted := &model.PostedScore{}
+
+	if err := s.db.Get(posted, `SELECT * FROM "posted_scores" WHERE message_id=$1`, messageID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return posted, nil
+}
+
+// Save creates the posted score
+func (s PostedScoreService) Save(posted *model.PostedScore) error {
+	now := time.Now().UTC()
+	posted.CreatedAt = now
+	posted.UpdatedAt = now
+
+	q := `INSERT INTO "posted_scores" (
+		created_at,
+		updated_at,
+		message_id,
+		channel_id,
+		song_id,
+		score_key,
+		rate
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id`
+
+	return s.db.Get(&posted.ID, q,
+		posted.CreatedAt,
+		posted.UpdatedAt,
+		posted.MessageID,
+		posted.ChannelID,
+		posted.SongID,
+		posted.ScoreKey,
+		posted.Rate,
+	)
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type RivalService struct {
+	db *sqlx.DB
+}
+
+// NewRivalService returns a service for managing the rivals of users
+func NewRivalService(db *sqlx.DB) RivalService {
+	return RivalService{db: db}
+}
+
+// Add adds a rival for the user. If the user already has the rival, returns false, nil
+func (s RivalService) Add(userID, rivalID uint, discordID string) (bool, error) {
+	now := time.Now().UTC()
+
+	query := `
+		INSERT INTO "rivals" (
+			created_at,
+			updated_at,
+			user_id,
+			rival_id,
+			discord_user_id
+		)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	if _, err := s.db.Exec(query, now, now, userID, rivalID, discordID); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code.Name() == "unique_violation" {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Remove removes a rival from the user. If the user had the rival, returns true, nil
+func (s RivalService) Remove(userID, rivalID uint) (bool, error) {
+	var err error
+	var result sql.Result
+
+	query := `
+		DELETE FROM "rivals"
+		WHERE user_id=$1 AND rival_id=$2
+	`
+
+	if result, err = s.db.Exec(query, userID, rivalID); err != nil {
+		return false, err
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// GetRivals returns the users that the user has added as rivals
+func (s RivalService) GetRivals(userID uint) ([]*model.EtternaUser, error) {
+	var users []*model.EtternaUser
+
+	query := `
+		SELECT u.*
+		FROM "etterna_users" u
+		INNER JOIN "rivals" r ON r.rival_id = u.id
+		WHERE r.user_id=$1
+		ORDER BY lower(u.username)
+	`
+
+	if err := s.db.Select(&users, query, userID); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetFollowers returns the users who have added the user as a rival
+func (s RivalService) GetFollowers(rivalID uint) ([]*model.RivalFollower, error) {
+	var followers []*model.RivalFollower
+
+	query := `
+		SELECT u.*, r.discord_user_id
+		FROM "etterna_users" u
+		INNER JOIN "rivals" r ON r.user_id = u.id
+		WHERE r.rival_id=$1
+	`
+
+	if err := s.db.Select(&followers, query, rivalID); err != nil {
+		return nil, err
+	}
+
+	return followers, nil
+}
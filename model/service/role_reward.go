@@ -0,0 +1,86 @@
+package service
+
+import (
+	"time"
+
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type RoleRewardService struct {
+	db *sqlx.DB
+}
+
+// NewRoleRewardService returns a service for managing the roles that servers give
+// players for their ratings and ranks
+func NewRoleRewardService(db *sqlx.DB) RoleRewardService {
+	return RoleRewardService{db: db}
+}
+
+// Save creates the reward, or replaces the server's existing reward for the role
+func (s RoleRewardService) Save(reward *model.RoleReward) error {
+	now := time.Now().UTC()
+	reward.UpdatedAt = now
+
+	if reward.ID == 0 {
+		reward.CreatedAt = now
+	}
+
+	query := `
+		INSERT INTO "role_rewards" (
+			created_at,
+			updated_at,
+			server_id,
+			role_id,
+			kind,
+			skillset,
+			threshold
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (server_id, role_id) DO UPDATE SET
+			updated_at=EXCLUDED.updated_at,
+			kind=EXCLUDED.kind,
+			skillset=EXCLUDED.skillset,
+			threshold=EXCLUDED.threshold
+		RETURNING id`
+
+	return s.db.Get(&reward.ID, query,
+		reward.CreatedAt,
+		reward.UpdatedAt,
+		reward.ServerID,
+		reward.RoleID,
+		reward.Kind,
+		reward.Skillset,
+		reward.Threshold,
+	)
+}
+
+// Delete removes the server's reward for the role. If there wasn't one, returns
+// false, nil
+func (s RoleRewardService) Delete(serverID, roleID string) (bool, error) {
+	result, err := s.db.Exec(`DELETE FROM "role_rewards" WHERE server_id=$1 AND role_id=$2`, serverID, roleID)
+
+	if err != nil {
+		return false, err
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// GetAll returns the server's role rewards, ordered by kind, skillset and threshold
+func (s RoleRewardService) GetAll(serverID string) ([]*model.RoleReward, error) {
+	var rewards []*model.RoleReward
+
+	query := `
+		SELECT *
+		FROM "role_rewards"
+		WHERE server_id=$1
+		ORDER BY kind, skillset, threshold`
+
+	if err := s.db.Select(&rewards, query, serverID); err != nil {
+		return nil, err
+	}
+
+	return rewards, nil
+}
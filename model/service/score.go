@@ -0,0 +1,412 @@
+package service
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Kangaroux/etternabot/etterna"
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// The score column for each skillset's rating
+var skillsetColumns = map[etterna.Skillset]string{
+	etterna.SkillsetOverall:    "msd_overall",
+	etterna.SkillsetStream:     "msd_stream",
+	etterna.SkillsetJumpstream: "msd_jumpstream",
+	etterna.SkillsetHandstream: "msd_handstream",
+	etterna.SkillsetStamina:    "msd_stamina",
+	etterna.SkillsetJackSpeed:  "msd_jackspeed",
+	etterna.SkillsetChordjack:  "msd_chordjack",
+	etterna.SkillsetTechnical:  "msd_technical",
+}
+
+type ScoreService struct {
+	db *sqlx.DB
+}
+
+// NewScoreService returns a service for managing the score history in the database
+func NewScoreService(db *sqlx.DB) ScoreService {
+	return ScoreService{db: db}
+}
+
+// Get returns the score with the given key, or nil if the score has not been saved
+func (s ScoreService) Get(scoreKey string) (*model.Score, error) {
+	score := &model.Score{}
+
+	if err := s.db.Get(score, `SELECT * FROM "scores" WHERE score_key=$1`, scoreKey); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return score, nil
+}
+
+// Save creates the score, or updates it if a score with the same key already exists.
+// EO reuses the key when a score is overwritten so the existing record is replaced
+func (s ScoreService) Save(score *model.Score) error {
+	now := time.Now().UTC()
+	score.UpdatedAt = now
+
+	if score.CreatedAt.IsZero() {
+		score.CreatedAt = now
+	}
+
+	q := `INSERT INTO "scores" (
+		created_at,
+		updated_at,
+		score_key,
+		user_id,
+		song_id,
+		rate,
+		accuracy,
+		marvelous,
+		perfect,
+		great,
+		good,
+		bad,
+		miss,
+		max_combo,
+		mines_hit,
+		msd_overall,
+		msd_stream,
+		msd_jumpstream,
+		msd_handstream,
+		msd_stamina,
+		msd_jackspeed,
+		msd_chordjack,
+		msd_technical,
+		nerf,
+		mods,
+		valid,
+		played_at,
+		tracked
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28)
+	ON CONFLICT (score_key) DO UPDATE SET
+		updated_at=EXCLUDED.updated_at,
+		user_id=EXCLUDED.user_id,
+		song_id=EXCLUDED.song_id,
+		rate=EXCLUDED.rate,
+		accuracy=EXCLUDED.accuracy,
+		marvelous=EXCLUDED.marvelous,
+		perfect=EXCLUDED.perfect,
+		great=EXCLUDED.great,
+		good=EXCLUDED.good,
+		bad=EXCLUDED.bad,
+		miss=EXCLUDED.miss,
+		max_combo=EXCLUDED.max_combo,
+		mines_hit=EXCLUDED.mines_hit,
+		msd_overall=EXCLUDED.msd_overall,
+		msd_stream=EXCLUDED.msd_stream,
+		msd_jumpstream=EXCLUDED.msd_jumpstream,
+		msd_handstream=EXCLUDED.msd_handstream,
+		msd_stamina=EXCLUDED.msd_stamina,
+		msd_jackspeed=EXCLUDED.msd_jackspeed,
+		msd_chordjack=EXCLUDED.msd_chordjack,
+		msd_technical=EXCLUDED.msd_technical,
+		nerf=EXCLUDED.nerf,
+		mods=EXCLUDED.mods,
+		valid=EXCLUDED.valid,
+		played_at=EXCLUDED.played_at,
+		tracked=scores.tracked OR EXCLUDED.tracked
+	RETURNING id`
+
+	return s.db.Get(&score.ID, q,
+		score.CreatedAt,
+		score.UpdatedAt,
+		score.ScoreKey,
+		score.UserID,
+		score.SongID,
+		score.Rate,
+		score.Accuracy,
+		score.Marvelous,
+		score.Perfect,
+		score.Great,
+		score.Good,
+		score.Bad,
+		score.Miss,
+		score.MaxCombo,
+		score.MinesHit,
+		score.MSDOverall,
+		score.MSDStream,
+		score.MSDJumpstream,
+		score.MSDHandstream,
+		score.MSDStamina,
+		score.MSDJackSpeed,
+		score.MSDChordjack,
+		score.MSDTechnical,
+		score.Nerfed,
+		score.Mods,
+		score.Valid,
+		score.PlayedAt,
+		score.Tracked,
+	)
+}
+
+// SaveSummary creates the score from a user's score list. If the score already exists,
+// only the fields that are in the score list are updated
+func (s ScoreService) SaveSummary(score *model.Score) error {
+	now := time.Now().UTC()
+	score.UpdatedAt = now
+
+	if score.CreatedAt.IsZero() {
+		score.CreatedAt = now
+	}
+
+	q := `INSERT INTO "scores" (
+		created_at,
+		updated_at,
+		score_key,
+		user_id,
+		song_id,
+		rate,
+		accuracy,
+		marvelous,
+		perfect,
+		great,
+		good,
+		bad,
+		miss,
+		max_combo,
+		mines_hit,
+		msd_overall,
+		msd_stream,
+		msd_jumpstream,
+		msd_handstream,
+		msd_stamina,
+		msd_jackspeed,
+		msd_chordjack,
+		msd_technical,
+		nerf,
+		mods,
+		valid,
+		played_at
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27)
+	ON CONFLICT (score_key) DO UPDATE SET
+		updated_at=EXCLUDED.updated_at,
+		rate=EXCLUDED.rate,
+		accuracy=EXCLUDED.accuracy,
+		msd_overall=EXCLUDED.msd_overall,
+		msd_stream=EXCLUDED.msd_stream,
+		msd_jumpstream=EXCLUDED.msd_jumpstream,
+		msd_handstream=EXCLUDED.msd_handstream,
+		msd_stamina=EXCLUDED.msd_stamina,
+		msd_jackspeed=EXCLUDED.msd_jackspeed,
+		msd_chordjack=EXCLUDED.msd_chordjack,
+		msd_technical=EXCLUDED.msd_technical,
+		nerf=EXCLUDED.nerf
+	RETURNING id`
+
+	return s.db.Get(&score.ID, q,
+		score.CreatedAt,
+		score.UpdatedAt,
+		score.ScoreKey,
+		score.UserID,
+		score.SongID,
+		score.Rate,
+		score.Accuracy,
+		score.Marvelous,
+		score.Perfect,
+		score.Great,
+		score.Good,
+		score.Bad,
+		score.Miss,
+		score.MaxCombo,
+		score.MinesHit,
+		score.MSDOverall,
+		score.MSDStream,
+		score.MSDJumpstream,
+		score.MSDHandstream,
+		score.MSDStamina,
+		score.MSDJackSpeed,
+		score.MSDChordjack,
+		score.MSDTechnical,
+		score.Nerfed,
+		score.Mods,
+		score.Valid,
+		score.PlayedAt,
+	)
+}
+
+// CountTracked returns the number of the user's tracked, valid scores with at least the
+// accuracy
+func (s ScoreService) CountTracked(userID uint, minAccuracy float64) (int, error) {
+	var count int
+
+	query := `SELECT COUNT(*) FROM "scores" WHERE user_id=$1 AND tracked AND valid AND accuracy >= $2`
+
+	if err := s.db.Get(&count, query, userID, minAccuracy); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetKeys returns the keys of all of the saved scores played by the user
+func (s ScoreService) GetKeys(userID uint) ([]string, error) {
+	var keys []string
+
+	if err := s.db.Select(&keys, `SELECT score_key FROM "scores" WHERE user_id=$1`, userID); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// GetTrackedHistory returns all of the user's tracked scores ordered by when they were
+// played, oldest first. Synced scores are left out since they only have the date they
+// were played, not the time
+func (s ScoreService) GetTrackedHistory(userID uint) ([]*model.Score, error) {
+	var scores []*model.Score
+
+	query := `SELECT * FROM "scores" WHERE user_id=$1 AND tracked ORDER BY played_at, id`
+
+	if err := s.db.Select(&scores, query, userID); err != nil {
+		return nil, err
+	}
+
+	return scores, nil
+}
+
+// GetBestOnSong returns the user's most accurate score on the song at the rate, or nil
+// if the user doesn't have a saved score on it
+func (s ScoreService) GetBestOnSong(userID uint, songID int, rate float64) (*model.Score, error) {
+	score := &model.Score{}
+
+	query := `
+		SELECT *
+		FROM "scores"
+		WHERE user_id=$1 AND song_id=$2 AND rate=CAST($3 AS DECIMAL(4, 2)) AND valid
+		ORDER BY accuracy DESC
+		LIMIT 1`
+
+	if err := s.db.Get(score, query, userID, songID, rate); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return score, nil
+}
+
+// GetBestPerSongRate returns the user's most accurate score on each song at each rate
+func (s ScoreService) GetBestPerSongRate(userID uint) ([]*model.Score, error) {
+	var scores []*model.Score
+
+	query := `
+		SELECT DISTINCT ON (song_id, rate) *
+		FROM "scores"
+		WHERE user_id=$1 AND valid
+		ORDER BY song_id, rate, accuracy DESC`
+
+	if err := s.db.Select(&scores, query, userID); err != nil {
+		return nil, err
+	}
+
+	return scores, nil
+}
+
+// GetChartRatings estimates the rating of each song at each rate from the valid scores
+// of every user except the excluded one. A score's rating goes up with its accuracy,
+// so the lowest rating of the scores with at least the given accuracy is used as the
+// estimate. Only songs that are cached are included
+func (s ScoreService) GetChartRatings(skillset etterna.Skillset, minAccuracy, minRating, maxRating float64, excludeUserID uint) ([]*model.ChartRating, error) {
+	var ratings []*model.ChartRating
+
+	query := `
+		SELECT sc.song_id, sc.rate, MIN(sc.` + skillsetColumns[skillset] + `) AS rating
+		FROM "scores" sc
+		INNER JOIN "songs" so ON so.etterna_id=sc.song_id
+		WHERE sc.valid AND sc.accuracy >= $1 AND sc.user_id <> $2
+		GROUP BY sc.song_id, sc.rate
+		HAVING MIN(sc.` + skillsetColumns[skillset] + `) BETWEEN $3 AND $4
+		ORDER BY rating`
+
+	if err := s.db.Select(&ratings, query, minAccuracy, excludeUserID, minRating, maxRating); err != nil {
+		return nil, err
+	}
+
+	return ratings, nil
+}
+
+// GetServerScores returns the valid scores played within the time range by users who
+// are registered in the server
+func (s ScoreService) GetServerScores(serverID string, start, end time.Time) ([]*model.Score, error) {
+	var scores []*model.Score
+
+	query := `
+		SELECT sc.* FROM "scores" sc
+		INNER JOIN "etterna_users" u ON u.id=sc.user_id
+		INNER JOIN "registrations" r ON r.username=u.username
+		WHERE r.server_id=$1 AND sc.valid AND sc.played_at >= $2 AND sc.played_at < $3`
+
+	if err := s.db.Select(&scores, query, serverID, start.UTC(), end.UTC()); err != nil {
+		return nil, err
+	}
+
+	return scores, nil
+}
+
+// GetServerPersonalBests returns the scores played within the time range by users who
+// are registered in the server which are more accurate than any of the user's earlier
+// scores on the song at the same rate. Scores on songs the user hadn't played at that
+// rate before aren't included
+func (s ScoreService) GetServerPersonalBests(serverID string, start, end time.Time) ([]*model.Score, error) {
+	var scores []*model.Score
+
+	query := `
+		SELECT sc.* FROM "scores" sc
+		INNER JOIN "etterna_users" u ON u.id=sc.user_id
+		INNER JOIN "registrations" r ON r.username=u.username
+		WHERE r.server_id=$1 AND sc.valid AND sc.played_at >= $2 AND sc.played_at < $3
+		AND EXISTS (
+			SELECT 1 FROM "scores" o
+			WHERE o.user_id=sc.user_id AND o.song_id=sc.song_id AND o.rate=sc.rate
+			AND o.valid AND o.played_at < sc.played_at
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM "scores" o
+			WHERE o.user_id=sc.user_id AND o.song_id=sc.song_id AND o.rate=sc.rate
+			AND o.valid AND o.played_at < sc.played_at AND o.accuracy >= sc.accuracy
+		)`
+
+	if err := s.db.Select(&scores, query, serverID, start.UTC(), end.UTC()); err != nil {
+		return nil, err
+	}
+
+	return scores, nil
+}
+
+// GetLastSync returns when the user's score list was last synced, or nil if it hasn't
+// been synced yet
+func (s ScoreService) GetLastSync(userID uint) (*time.Time, error) {
+	var syncedAt time.Time
+
+	if err := s.db.Get(&syncedAt, `SELECT synced_at FROM "score_syncs" WHERE user_id=$1`, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &syncedAt, nil
+}
+
+// SetLastSync records when the user's score list was synced
+func (s ScoreService) SetLastSync(userID uint, t time.Time) error {
+	q := `INSERT INTO "score_syncs" (user_id, synced_at)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET synced_at=EXCLUDED.synced_at`
+
+	_, err := s.db.Exec(q, userID, t)
+
+	return err
+}
@@ -2,11 +2,17 @@ package service
 
 import (
 	"database/sql"
+	"strings"
+	"unicode"
 
 	"github.com/Kangaroux/etternabot/model"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// Number of letters from the start of each word that search candidates must contain
+const candidatePrefixLength = 3
+
 type SongService struct {
 	db *sqlx.DB
 }
@@ -29,6 +35,70 @@ func (s SongService) Get(etternaID int) (*model.Song, error) {
 	return song, nil
 }
 
+// Search returns cached songs whose name contains the query (case insensitive). Exact
+// matches and shorter names are returned first
+func (s SongService) Search(query string, limit int) ([]*model.Song, error) {
+	var songs []*model.Song
+
+	q := `
+		SELECT * FROM "songs"
+		WHERE name ILIKE '%' || $1 || '%'
+		ORDER BY lower(name)=lower($3) DESC, length(name), name
+		LIMIT $2
+	`
+
+	if err := s.db.Select(&songs, q, escapeLike(query), limit, query); err != nil {
+		return nil, err
+	}
+
+	return songs, nil
+}
+
+// SearchCandidates returns the cached songs with a name or artist containing the first
+// few letters of any word in the query, most relevant first. This narrows down the
+// songs that need to be fuzzy matched without missing ones that have a typo later in
+// the word
+func (s SongService) SearchCandidates(query string, limit int) ([]*model.Song, error) {
+	var songs []*model.Song
+	var patterns []string
+
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, w := range words {
+		if r := []rune(w); len(r) > candidatePrefixLength {
+			w = string(r[:candidatePrefixLength])
+		}
+
+		patterns = append(patterns, "%"+escapeLike(w)+"%")
+	}
+
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	// Exact and prefix name matches come first, then the songs matching the most words,
+	// so the song being searched for isn't cut off by the limit
+	q := `
+		SELECT * FROM "songs"
+		WHERE name ILIKE ANY($1) OR artist ILIKE ANY($1)
+		ORDER BY
+			lower(name)=lower($3) DESC,
+			name ILIKE $4 || '%' DESC,
+			(SELECT count(*) FROM unnest($1::text[]) p WHERE name ILIKE p OR artist ILIKE p) DESC,
+			length(name),
+			name
+		LIMIT $2
+	`
+
+	if err := s.db.Select(&songs, q, pq.StringArray(patterns), limit, query, escapeLike(query)); err != nil {
+		return nil, err
+	}
+
+	return songs, nil
+}
+
 func (s SongService) Save(song *model.Song) error {
 	var err error
 
@@ -65,3 +135,8 @@ func (s SongService) Save(song *model.Song) error {
 
 	return err
 }
+
+// escapeLike escapes the wildcard characters in a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
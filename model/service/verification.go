@@ -0,0 +1,80 @@
+package service
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Kangaroux/etternabot/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type VerificationService struct {
+	db *sqlx.DB
+}
+
+// NewVerificationService returns a service for managing pending registrations
+func NewVerificationService(db *sqlx.DB) VerificationService {
+	return VerificationService{db: db}
+}
+
+// Get returns the discord user's pending verification in the server, or nil if they
+// don't have one
+func (s VerificationService) Get(serverID, discordID string) (*model.Verification, error) {
+	v := &model.Verification{}
+
+	query := `SELECT * FROM "verifications" WHERE server_id=$1 AND discord_user_id=$2`
+
+	if err := s.db.Get(v, query, serverID, discordID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// Save creates the verification, or replaces the discord user's pending verification
+// in the server
+func (s VerificationService) Save(v *model.Verification) error {
+	now := time.Now().UTC()
+	v.CreatedAt = now
+	v.UpdatedAt = now
+
+	query := `
+		INSERT INTO "verifications" (
+			created_at,
+			updated_at,
+			server_id,
+			discord_user_id,
+			username,
+			code,
+			server_only
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (server_id, discord_user_id) DO UPDATE SET
+			created_at=EXCLUDED.created_at,
+			updated_at=EXCLUDED.updated_at,
+			username=EXCLUDED.username,
+			code=EXCLUDED.code,
+			server_only=EXCLUDED.server_only
+		RETURNING id`
+
+	return s.db.Get(&v.ID, query,
+		v.CreatedAt,
+		v.UpdatedAt,
+		v.ServerID,
+		v.DiscordUserID,
+		v.Username,
+		v.Code,
+		v.ServerOnly,
+	)
+}
+
+// Delete removes the discord user's pending verification in the server
+func (s VerificationService) Delete(serverID, discordID string) error {
+	_, err := s.db.Exec(`DELETE FROM "verifications" WHERE server_id=$1 AND discord_user_id=$2`, serverID, discordID)
+
+	return err
+}
@@ -3,6 +3,13 @@ package model
 type SongServicer interface {
 	Get(etternaID int) (*Song, error)
 	Save(song *Song) error
+
+	// Searches the cached songs for songs with a name containing the query
+	Search(query string, limit int) ([]*Song, error)
+
+	// Gets the cached songs that could fuzzy match the query, which are the songs with
+	// a name or artist containing the start of any word in the query
+	SearchCandidates(query string, limit int) ([]*Song, error)
 }
 
 type Song struct {
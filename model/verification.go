@@ -0,0 +1,24 @@
+package model
+
+type VerificationServicer interface {
+	// Gets the discord user's pending verification in the server
+	Get(serverID, discordID string) (*Verification, error)
+
+	// Creates the verification, replacing the discord user's pending verification in
+	// the server if they have one
+	Save(v *Verification) error
+
+	// Removes the discord user's pending verification in the server
+	Delete(serverID, discordID string) error
+}
+
+// Verification is a pending registration. The discord user is registered once the
+// code is found on the etterna user's profile
+type Verification struct {
+	BaseModel
+	ServerID      string `db:"server_id"`
+	DiscordUserID string `db:"discord_user_id"`
+	Username      string `db:"username"` // The etterna user being claimed
+	Code          string `db:"code"`
+	ServerOnly    bool   `db:"server_only"` // Whether to register in the server only instead of creating a global link
+}
@@ -8,12 +8,22 @@ import (
 )
 
 type Bot struct {
-	DB      *sqlx.DB
-	API     etterna.EtternaAPI
-	Session *discordgo.Session
-	Servers model.DiscordServerServicer
-	Songs   model.SongServicer
-	Users   model.EtternaUserServicer
+	DB            *sqlx.DB
+	API           etterna.EtternaAPI
+	Session       *discordgo.Session
+	Achievements  model.AchievementServicer
+	Challenges    model.ChallengeServicer
+	Channels      model.DiscordChannelServicer
+	Goals         model.GoalServicer
+	Matches       model.MatchServicer
+	Posted        model.PostedScoreServicer
+	Rivals        model.RivalServicer
+	Roles         model.RoleRewardServicer
+	Servers       model.DiscordServerServicer
+	Scores        model.ScoreServicer
+	Songs         model.SongServicer
+	Users         model.EtternaUserServicer
+	Verifications model.VerificationServicer
 }
 
 type Play struct {
@@ -2,8 +2,37 @@ package util
 
 import (
 	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
+const (
+	day       = 24 * time.Hour
+	maxPeriod = 10 * 365 * day // Longest period that can be parsed
+)
+
+var rePeriod = regexp.MustCompile(`^(\d+)\s*([a-z]+)$`)
+
+// Units that can be used in a period, along with their (approximate) length
+var periodUnits = map[string]time.Duration{
+	"d":      day,
+	"day":    day,
+	"days":   day,
+	"w":      7 * day,
+	"week":   7 * day,
+	"weeks":  7 * day,
+	"m":      30 * day,
+	"month":  30 * day,
+	"months": 30 * day,
+	"y":      365 * day,
+	"year":   365 * day,
+	"years":  365 * day,
+}
+
 // GetEqualitySign compares the two values and returns the corresponding equality sign
 func GetEqualitySign(a, b float64) rune {
 	if a > b {
@@ -33,3 +62,138 @@ func RoundToPrecision(num float64, precision int) float64 {
 
 	return math.Round(f) / scalar
 }
+
+// ParsePeriod parses a user-friendly length of time such as "7d", "2 weeks" or "month".
+// A month is treated as 30 days and a year as 365 days. Returns false if the period
+// isn't valid or is longer than 10 years
+func ParsePeriod(s string) (time.Duration, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	if d, ok := periodUnits[s]; ok && len(s) > 1 {
+		return d, true
+	}
+
+	match := rePeriod.FindStringSubmatch(s)
+
+	if match == nil {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(match[1])
+	unit, ok := periodUnits[match[2]]
+
+	if err != nil || !ok || n < 1 || time.Duration(n) > maxPeriod/unit {
+		return 0, false
+	}
+
+	return time.Duration(n) * unit, true
+}
+
+// FormatPeriod returns a user-friendly description of a period, e.g. "7 days"
+func FormatPeriod(d time.Duration) string {
+	days := int(d / day)
+
+	if days == 1 {
+		return "1 day"
+	}
+
+	return strconv.Itoa(days) + " days"
+}
+
+// FuzzyMatch scores how closely the query matches the target, from 0 (no match) to
+// 1 (exact match). Case and punctuation are ignored. Each word in the query is
+// compared to the closest word in the target so small typos still match
+func FuzzyMatch(query, target string) float64 {
+	q := normalizeWords(query)
+	t := normalizeWords(target)
+
+	if len(q) == 0 || len(t) == 0 {
+		return 0
+	}
+
+	qs := strings.Join(q, " ")
+	ts := strings.Join(t, " ")
+
+	if qs == ts {
+		return 1
+	} else if strings.HasPrefix(ts, qs) {
+		return 0.95
+	} else if strings.Contains(ts, qs) {
+		return 0.9
+	}
+
+	total := 0.0
+
+	for _, qw := range q {
+		best := 0.0
+
+		for _, tw := range t {
+			var sim float64
+
+			if strings.HasPrefix(tw, qw) {
+				sim = 1
+			} else {
+				sim = 1 - float64(levenshtein(qw, tw))/math.Max(float64(utf8.RuneCountInString(qw)), float64(utf8.RuneCountInString(tw)))
+			}
+
+			best = math.Max(best, sim)
+		}
+
+		total += best
+	}
+
+	// Scale down so a word-by-word match never beats a substring match
+	return total / float64(len(q)) * 0.85
+}
+
+// normalizeWords lowercases the string and splits it into words, ignoring any
+// punctuation. Apostrophes are dropped so contractions stay as one word
+func normalizeWords(s string) []string {
+	s = strings.NewReplacer("'", "", "’", "").Replace(strings.ToLower(s))
+
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ar := []rune(a)
+	br := []rune(b)
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(br)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,63 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePeriod(t *testing.T) {
+	t.Run("should parse valid periods", func(t *testing.T) {
+		cases := map[string]time.Duration{
+			"7d":      7 * day,
+			"2w":      14 * day,
+			"3 weeks": 21 * day,
+			"1m":      30 * day,
+			"month":   30 * day,
+			"1Y":      365 * day,
+		}
+
+		for s, expected := range cases {
+			d, ok := ParsePeriod(s)
+
+			require.True(t, ok, s)
+			require.Equal(t, expected, d, s)
+		}
+	})
+
+	t.Run("should reject invalid periods", func(t *testing.T) {
+		for _, s := range []string{"", "d", "0d", "7", "7x", "-1w", "jesse", "11y", "999999999w", "99999999999999999999d"} {
+			_, ok := ParsePeriod(s)
+			require.False(t, ok, s)
+		}
+	})
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	t.Run("should rank closer matches higher", func(t *testing.T) {
+		exact := FuzzyMatch("eternal drain", "ETERNAL DRAIN")
+		prefix := FuzzyMatch("eternal", "Eternal Drain")
+		typo := FuzzyMatch("eternl drian", "Eternal Drain")
+		other := FuzzyMatch("eternl drian", "Bagpipe")
+
+		require.Equal(t, 1.0, exact)
+		require.True(t, prefix < exact)
+		require.True(t, typo < prefix)
+		require.True(t, other < typo)
+	})
+
+	t.Run("should ignore punctuation", func(t *testing.T) {
+		require.Equal(t, 1.0, FuzzyMatch("dont stop", "Don't Stop!"))
+	})
+
+	t.Run("should compare non-ASCII words by character", func(t *testing.T) {
+		require.True(t, FuzzyMatch("東京", "大阪") < 0.5)
+		require.True(t, FuzzyMatch("привет", "пока") < 0.5)
+	})
+
+	t.Run("should not match empty strings", func(t *testing.T) {
+		require.Equal(t, 0.0, FuzzyMatch("", "Bagpipe"))
+		require.Equal(t, 0.0, FuzzyMatch("bagpipe", ""))
+	})
+}